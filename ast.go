@@ -0,0 +1,150 @@
+package effe
+
+import "log/slog"
+
+// Node is a node of the syntax tree returned by Parse. Its Kind determines
+// which of the accessors are meaningful.
+type Node struct {
+	kind          NodeKind
+	rawValue      string
+	literalValue  Value
+	children      []*Node
+	operatorValue Operator
+	// For references, the parsed reference, including any '$' markers
+	area Area
+	span Span
+}
+
+// Position is an offset into the text of a cell, counting both bytes and
+// runes from 0
+type Position struct {
+	Byte int
+	Rune int
+}
+
+// Span is the text of a cell from Start up to, but not including, End, eg: the
+// span of B2 in =A1+B2 is from 4 to 6
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// LogValue logs a span as its byte and rune offsets
+func (s Span) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("start", s.Start.Byte),
+		slog.Int("end", s.End.Byte),
+		slog.Int("startRune", s.Start.Rune),
+		slog.Int("endRune", s.End.Rune),
+	)
+}
+
+// union returns the smallest span covering both s and o
+func (s Span) union(o Span) Span {
+	if o.Start.Byte < s.Start.Byte {
+		s.Start = o.Start
+	}
+	if o.End.Byte > s.End.Byte {
+		s.End = o.End
+	}
+	return s
+}
+
+// NodeKind distinguishes the kinds of Node
+type NodeKind int
+
+const (
+	// A call, eg: SUM(A1,2). Text is the name, and Children the arguments.
+	NodeKindFunction NodeKind = iota
+	// A number, text, logical or error, eg: 1.5 or "a". Text is the literal as
+	// written, and Value its value.
+	NodeKindLiteral
+	// An operator and its operands, eg: 1+2, or the space in A:A 1:1
+	NodeKindOperator
+	// An omitted argument, eg: the second argument in IF(A1,,3)
+	NodeKindHole
+	// A reference, eg: $A$1 or B:C. Text is the reference as written, Area the
+	// cells referred to, and Value the Range from the RangeProvider.
+	NodeKindReference
+)
+
+// Operator is an operator of an operator node, eg: Percent in 50%
+type Operator int
+
+const (
+	Intersection Operator = iota
+	UnaryNegation
+	Percent
+	Exponentiation
+	Multiplication
+	Division
+	Addition
+	Subtraction
+	Concatenation
+	Equality
+	GreaterThan
+	LessThan
+	GreaterThanOrEqual
+	LessThanOrEqual
+	Inequality
+	// A leading +, eg: =+A1, which leaves its operand unchanged
+	UnaryPlus
+)
+
+// ParseError describes a problem found while parsing, and the text causing it
+type ParseError struct {
+	Span    Span
+	Message string
+}
+
+func (pe ParseError) Error() string {
+	return pe.Message
+}
+
+func (n *Node) Kind() NodeKind {
+	return n.kind
+}
+
+// Span returns the text n was parsed from. The span of an operator or function
+// covers its operands or arguments, and a hole has an empty span.
+func (n *Node) Span() Span {
+	return n.span
+}
+
+// Text returns a literal or reference as written, eg: "a""b" or $A$1, or the
+// name of a function as written, eg: sum
+func (n *Node) Text() string {
+	return n.rawValue
+}
+
+// Value returns the value of a literal, or the Range of a reference
+func (n *Node) Value() Value {
+	return n.literalValue
+}
+
+// Area returns the cells a reference refers to
+func (n *Node) Area() Area {
+	return n.area
+}
+
+// Operator returns the operator of an operator node
+func (n *Node) Operator() Operator {
+	return n.operatorValue
+}
+
+// Children returns the operands of an operator, in order, or the arguments of
+// a function. The slice must not be modified.
+func (n *Node) Children() []*Node {
+	return n.children
+}
+
+// Inspect visits n and its descendants depth first, in the order they were
+// written. The children of a node are skipped when f returns false for it.
+func Inspect(n *Node, f func(*Node) bool) {
+	if !f(n) {
+		return
+	}
+	for _, c := range n.children {
+		Inspect(c, f)
+	}
+}
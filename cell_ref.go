@@ -0,0 +1,178 @@
+package effe
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The size of an excel sheet
+const (
+	MaxRows    = 1048576
+	MaxColumns = 16384
+)
+
+// CellRef is one end of a reference in A1 notation, eg: the B2 in A1:B2. A
+// reference to whole rows, like 1:3, has no Column, and a reference to whole
+// columns, like A:C, has no Row. Column and Row count from 1, and are 0 when
+// absent.
+//
+// AbsoluteColumn and AbsoluteRow record '$' markers, eg: $A1 or A$1, which
+// anchor that part of the reference when a formula is moved.
+type CellRef struct {
+	Column         int
+	Row            int
+	AbsoluteColumn bool
+	AbsoluteRow    bool
+}
+
+// ParseCellRef parses a reference to a single cell, eg: B2 or $B$2
+func ParseCellRef(text string) (CellRef, error) {
+	c, err := parseRefEnd(text)
+	if err != nil {
+		return CellRef{}, err
+	}
+	if c.Column == 0 || c.Row == 0 {
+		return CellRef{}, fmt.Errorf("not a cell reference: %q", text)
+	}
+	return c, nil
+}
+
+// parseRefEnd parses a cell, a column or a row
+func parseRefEnd(text string) (CellRef, error) {
+	var c CellRef
+	i := 0
+	absolute := false
+	if i < len(text) && text[i] == '$' {
+		absolute = true
+		i++
+	}
+
+	start := i
+	for ; i < len(text); i++ {
+		letter, ok := columnLetter(text[i])
+		if !ok {
+			break
+		}
+		c.Column = c.Column*26 + letter
+		if c.Column > MaxColumns {
+			return CellRef{}, fmt.Errorf("column out of range: %q", text)
+		}
+	}
+	if i > start {
+		c.AbsoluteColumn, absolute = absolute, false
+		if i < len(text) && text[i] == '$' {
+			absolute = true
+			i++
+		}
+	}
+
+	start = i
+	for ; i < len(text) && text[i] >= '0' && text[i] <= '9'; i++ {
+		c.Row = c.Row*10 + int(text[i]-'0')
+		if c.Row > MaxRows {
+			return CellRef{}, fmt.Errorf("row out of range: %q", text)
+		}
+	}
+	if i > start {
+		if text[start] == '0' {
+			return CellRef{}, fmt.Errorf("invalid row: %q", text)
+		}
+		c.AbsoluteRow = absolute
+	} else if absolute {
+		return CellRef{}, fmt.Errorf("misplaced '$': %q", text)
+	}
+
+	if i != len(text) || (c.Column == 0 && c.Row == 0) {
+		return CellRef{}, fmt.Errorf("invalid reference: %q", text)
+	}
+	return c, nil
+}
+
+// columnLetter returns the value of a column letter, where A is 1
+func columnLetter(b byte) (int, bool) {
+	switch {
+	case b >= 'A' && b <= 'Z':
+		return int(b-'A') + 1, true
+	case b >= 'a' && b <= 'z':
+		return int(b-'a') + 1, true
+	}
+	return 0, false
+}
+
+// ColumnName returns the letters naming a column, eg: 1 is A, and 28 is AB
+func ColumnName(column int) string {
+	var name []byte
+	for ; column > 0; column = (column - 1) / 26 {
+		name = append([]byte{byte('A' + (column-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+func (c CellRef) String() string {
+	var sb strings.Builder
+	if c.Column != 0 {
+		if c.AbsoluteColumn {
+			sb.WriteByte('$')
+		}
+		sb.WriteString(ColumnName(c.Column))
+	}
+	if c.Row != 0 {
+		if c.AbsoluteRow {
+			sb.WriteByte('$')
+		}
+		sb.WriteString(strconv.Itoa(c.Row))
+	}
+	return sb.String()
+}
+
+// Area is a rectangular reference, eg: A1:C10, whole columns A:C, or whole rows
+// 1:3. A reference to a single cell has From equal to To.
+type Area struct {
+	From, To CellRef
+}
+
+// ParseArea parses a cell, or a pair of cells, columns or rows separated by ':'
+func ParseArea(text string) (Area, error) {
+	first, second, isPair := strings.Cut(text, ":")
+	if !isPair {
+		c, err := ParseCellRef(first)
+		return Area{c, c}, err
+	}
+
+	from, err := parseRefEnd(first)
+	if err != nil {
+		return Area{}, err
+	}
+	to, err := parseRefEnd(second)
+	if err != nil {
+		return Area{}, err
+	}
+	if (from.Column == 0) != (to.Column == 0) || (from.Row == 0) != (to.Row == 0) {
+		return Area{}, fmt.Errorf("mismatched ends of range: %q", text)
+	}
+	return Area{from, to}, nil
+}
+
+func (a Area) String() string {
+	if a.From == a.To && a.From.Column != 0 && a.From.Row != 0 {
+		return a.From.String()
+	}
+	return a.From.String() + ":" + a.To.String()
+}
+
+// Columns returns the first and last column covered, in order
+func (a Area) Columns() (int, int) {
+	if a.From.Column == 0 {
+		return 1, MaxColumns
+	}
+	return min(a.From.Column, a.To.Column), max(a.From.Column, a.To.Column)
+}
+
+// Rows returns the first and last row covered, in order
+func (a Area) Rows() (int, int) {
+	if a.From.Row == 0 {
+		return 1, MaxRows
+	}
+	return min(a.From.Row, a.To.Row), max(a.From.Row, a.To.Row)
+}
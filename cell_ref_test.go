@@ -0,0 +1,59 @@
+package effe
+
+import "testing"
+
+type areaTestCase struct {
+	text     string
+	expected Area
+}
+
+var areaCases []areaTestCase = []areaTestCase{
+	areaTestCase{"A1", Area{CellRef{Column: 1, Row: 1}, CellRef{Column: 1, Row: 1}}},
+	areaTestCase{"$B$2", Area{CellRef{2, 2, true, true}, CellRef{2, 2, true, true}}},
+	areaTestCase{"A$1:$C10", Area{CellRef{1, 1, false, true}, CellRef{3, 10, true, false}}},
+	areaTestCase{"A:A", Area{CellRef{Column: 1}, CellRef{Column: 1}}},
+	areaTestCase{"$AB:AC", Area{CellRef{Column: 28, AbsoluteColumn: true}, CellRef{Column: 29}}},
+	areaTestCase{"1:$3", Area{CellRef{Row: 1}, CellRef{Row: 3, AbsoluteRow: true}}},
+	areaTestCase{"XFD1048576", Area{CellRef{Column: MaxColumns, Row: MaxRows}, CellRef{Column: MaxColumns, Row: MaxRows}}},
+}
+
+var invalidAreas []string = []string{
+	"", "A", "1", "$", "A$", "$$A1", "A0", "A01", "1A", "A1:", ":A1", "A1:B", "A:1",
+	"A1:B2:C3", "XFE1", "A1048577", "A1 ", "A-1", "Ä1",
+}
+
+func TestParseArea(t *testing.T) {
+	for _, c := range areaCases {
+		t.Run(c.text, func(t *testing.T) {
+			a, err := ParseArea(c.text)
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if a != c.expected {
+				t.Errorf("Expected %v, but got %v", c.expected, a)
+			}
+			if a.String() != c.text {
+				t.Errorf("Expected to format as %v, but got %v", c.text, a)
+			}
+		})
+	}
+	for _, text := range invalidAreas {
+		if a, err := ParseArea(text); err == nil {
+			t.Errorf("Expected %q to be invalid, but got %v", text, a)
+		}
+	}
+}
+
+func TestAreaBounds(t *testing.T) {
+	a, _ := ParseArea("C5:A1")
+	if c1, c2 := a.Columns(); c1 != 1 || c2 != 3 {
+		t.Errorf("Expected columns 1 to 3, but got %v to %v", c1, c2)
+	}
+	if r1, r2 := a.Rows(); r1 != 1 || r2 != 5 {
+		t.Errorf("Expected rows 1 to 5, but got %v to %v", r1, r2)
+	}
+	a, _ = ParseArea("B:B")
+	if r1, r2 := a.Rows(); r1 != 1 || r2 != MaxRows {
+		t.Errorf("Expected every row, but got %v to %v", r1, r2)
+	}
+}
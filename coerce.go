@@ -0,0 +1,92 @@
+package effe
+
+import "strings"
+
+// ToScalar resolves a reference to the single value it refers to, through
+// implicit intersection when ctx.Cell is set, eg: A1:A10 in a formula in C3 is
+// A3. Other values are returned unchanged.
+func (v Value) ToScalar(ctx Context) Value {
+	if v.IsA == ValueKindRange {
+		return ctx.Ranges.Single(v.Range)
+	}
+	return v
+}
+
+// ToNumber converts v to a number: text is parsed, eg: " 12 " is 12, TRUE is
+// 1, FALSE and blank are 0. Text that is not a number is #VALUE!, and an error
+// is returned as is.
+func (v Value) ToNumber(ctx Context) (Number, error) {
+	v = v.ToScalar(ctx)
+	switch v.IsA {
+	case ValueKindNumber:
+		return v.Number, nil
+	case ValueKindText:
+		n, err := ctx.Numbers.ParseNumber(strings.TrimSpace(v.Text))
+		if err != nil {
+			return nil, ErrValue.Wrap(err)
+		}
+		return n, nil
+	case ValueKindLogical:
+		if v.Logical {
+			return ctx.Numbers.FromInt(1), nil
+		}
+		return ctx.Numbers.FromInt(0), nil
+	case ValueKindBlank:
+		return ctx.Numbers.FromInt(0), nil
+	case ValueKindError:
+		return nil, v.Error
+	}
+	return nil, ErrValue
+}
+
+// ToText converts v to text: numbers are formatted by ctx.Numbers, logicals are
+// TRUE or FALSE, and blank is "". An error is returned as is.
+func (v Value) ToText(ctx Context) (string, error) {
+	v = v.ToScalar(ctx)
+	switch v.IsA {
+	case ValueKindText:
+		return v.Text, nil
+	case ValueKindNumber:
+		return v.Number.String(), nil
+	case ValueKindLogical:
+		return logicalText(v.Logical), nil
+	case ValueKindBlank:
+		return "", nil
+	case ValueKindError:
+		return "", v.Error
+	}
+	return "", ErrValue
+}
+
+// ToLogical converts v to a logical: numbers are TRUE unless 0, text must be
+// TRUE or FALSE in any case, and blank is FALSE. Other text is #VALUE!, and an
+// error is returned as is.
+func (v Value) ToLogical(ctx Context) (bool, error) {
+	v = v.ToScalar(ctx)
+	switch v.IsA {
+	case ValueKindLogical:
+		return v.Logical, nil
+	case ValueKindNumber:
+		return ctx.Numbers.Cmp(v.Number, ctx.Numbers.FromInt(0)) != 0, nil
+	case ValueKindText:
+		switch {
+		case strings.EqualFold(v.Text, "TRUE"):
+			return true, nil
+		case strings.EqualFold(v.Text, "FALSE"):
+			return false, nil
+		}
+		return false, ErrValue
+	case ValueKindBlank:
+		return false, nil
+	case ValueKindError:
+		return false, v.Error
+	}
+	return false, ErrValue
+}
+
+func logicalText(l bool) string {
+	if l {
+		return "TRUE"
+	}
+	return "FALSE"
+}
@@ -0,0 +1,66 @@
+package effe
+
+import (
+	"errors"
+	"testing"
+)
+
+// coerceTestCase gives the expected result of each conversion of value, where
+// a non-nil error is expected instead of the corresponding result
+type coerceTestCase struct {
+	value      Value
+	number     string
+	numberErr  error
+	text       string
+	textErr    error
+	logical    bool
+	logicalErr error
+}
+
+var coerceCases []coerceTestCase = []coerceTestCase{
+	coerceTestCase{value: number("1.5").literalValue, number: "1.5", text: "1.5", logical: true},
+	coerceTestCase{value: number("0").literalValue, number: "0", text: "0", logical: false},
+	coerceTestCase{value: TextValue(" 12 "), number: "12", text: " 12 ", logicalErr: ErrValue},
+	coerceTestCase{value: TextValue("-1.5e1"), number: "-15", text: "-1.5e1", logicalErr: ErrValue},
+	coerceTestCase{value: TextValue("1_0"), numberErr: ErrValue, text: "1_0", logicalErr: ErrValue},
+	coerceTestCase{value: TextValue("0x1p4"), numberErr: ErrValue, text: "0x1p4", logicalErr: ErrValue},
+	coerceTestCase{value: TextValue("Inf"), numberErr: ErrValue, text: "Inf", logicalErr: ErrValue},
+	coerceTestCase{value: TextValue("NaN"), numberErr: ErrValue, text: "NaN", logicalErr: ErrValue},
+	coerceTestCase{value: TextValue("true"), numberErr: ErrValue, text: "true", logical: true},
+	coerceTestCase{value: TextValue("False"), numberErr: ErrValue, text: "False", logical: false},
+	coerceTestCase{value: LogicalValue(true), number: "1", text: "TRUE", logical: true},
+	coerceTestCase{value: LogicalValue(false), number: "0", text: "FALSE", logical: false},
+	coerceTestCase{value: BlankValue(), number: "0", text: "", logical: false},
+	coerceTestCase{value: reference("B3").literalValue, number: "23", text: "23", logical: true},
+	coerceTestCase{value: reference("Z1").literalValue, number: "0", text: "", logical: false},
+	coerceTestCase{value: reference("A1:A2").literalValue, numberErr: ErrValue, textErr: ErrValue, logicalErr: ErrValue},
+	coerceTestCase{value: ErrorValue(ErrNA), numberErr: ErrNA, textErr: ErrNA, logicalErr: ErrNA},
+}
+
+func TestCoerce(t *testing.T) {
+	for _, c := range coerceCases {
+		if n, err := c.value.ToNumber(testContext); c.numberErr != nil {
+			if !errors.Is(err, c.numberErr) {
+				t.Errorf("Expected %v converting %v to a number, but got %v", c.numberErr, c.value, err)
+			}
+		} else if err != nil || n.String() != c.number {
+			t.Errorf("Expected %v to be the number %v, but got %v %v", c.value, c.number, n, err)
+		}
+
+		if s, err := c.value.ToText(testContext); c.textErr != nil {
+			if !errors.Is(err, c.textErr) {
+				t.Errorf("Expected %v converting %v to text, but got %v", c.textErr, c.value, err)
+			}
+		} else if err != nil || s != c.text {
+			t.Errorf("Expected %v to be the text %q, but got %q %v", c.value, c.text, s, err)
+		}
+
+		if l, err := c.value.ToLogical(testContext); c.logicalErr != nil {
+			if !errors.Is(err, c.logicalErr) {
+				t.Errorf("Expected %v converting %v to a logical, but got %v", c.logicalErr, c.value, err)
+			}
+		} else if err != nil || l != c.logical {
+			t.Errorf("Expected %v to be %v, but got %v %v", c.value, c.logical, l, err)
+		}
+	}
+}
@@ -1,14 +1,48 @@
 package effe
 
+import (
+	"context"
+	"iter"
+	"log/slog"
+)
+
 // Context provides application resources necessary for effe formula evaluation
 type Context struct {
 	Numbers NumberProvider
 	Ranges  RangeProvider
+	// Cell, if set, is the cell holding the formula. A reference to several
+	// cells where a single value is needed then resolves to the one in the same
+	// row or column, eg: A1:A10 in a formula in C3 is A3.
+	Cell CellRef
+	// Logger, if set, receives debug level diagnostics from parsing and
+	// evaluation, eg: each token scanned, and where errors arise
+	Logger *slog.Logger
 }
 
+// debug logs a diagnostic, when there is a Logger that wants it
+func (ctx Context) debug(msg string, args ...any) {
+	if ctx.Logger != nil && ctx.Logger.Enabled(context.Background(), slog.LevelDebug) {
+		ctx.Logger.Debug(msg, args...)
+	}
+}
+
+// NumberProvider implements the numeric model used by formulas. Arithmetic
+// that has no answer in excel returns the matching ExcelError, eg: division by
+// zero returns ErrDiv0, and results that cannot be represented return ErrNum.
 type NumberProvider interface {
 	ParseNumber(text string) (Number, error)
+	FromInt(i int64) Number
 	Add(a Number, b Number) (Number, error)
+	Sub(a Number, b Number) (Number, error)
+	Mul(a Number, b Number) (Number, error)
+	Div(a Number, b Number) (Number, error)
+	Pow(a Number, b Number) (Number, error)
+	Neg(a Number) (Number, error)
+	// Cmp returns -1, 0 or +1 as a is less than, equal to, or greater than b
+	Cmp(a Number, b Number) int
+	ToFloat(a Number) float64
+	// ToInt truncates towards zero, returning #NUM! if a is out of range
+	ToInt(a Number) (int64, error)
 }
 
 type Number interface {
@@ -19,12 +53,41 @@ type Range interface {
 	IsSingleValue() bool
 }
 
+// RangeGeometry is implemented by Ranges that know their shape, for functions
+// such as INDEX, ROWS and COLUMNS. A range that cannot be resolved has no rows
+// or columns, and At returns its error.
+type RangeGeometry interface {
+	Range
+	// Dimensions returns the number of rows and columns covered
+	Dimensions() (rows int, columns int)
+	// Origin returns the top left cell
+	Origin() CellRef
+	// At returns a cell counting from 1 at the origin, or #REF! outside of the
+	// range, eg: At(2, 1) of B2:C3 is B3
+	At(row int, column int) Value
+}
+
+// RangeProvider resolves references to the values of cells. An empty cell has
+// a Blank value, which Single returns, and which Values may yield or skip.
+//
+// Values yields the cells of a range with their positions, row by row, and
+// stops as soon as the consumer does. A range that cannot be resolved yields a
+// single error, with a zero CellRef.
 type RangeProvider interface {
 	ParseRange(text string) Range
 	Intersect(a Range, b Range) Range
 	ImplicitIntersect(a Range, b Range) Range
 	Single(a Range) Value
-	Values(a Range) <-chan Value
+	Values(a Range) iter.Seq2[CellRef, Value]
+}
+
+// SparseRangeProvider is implemented by RangeProviders that can find the cells
+// that are not blank without visiting every cell, eg: so that =SUM(A:A) takes
+// time proportional to the data in column A.
+type SparseRangeProvider interface {
+	RangeProvider
+	// PopulatedValues is like Values, but skips blank cells
+	PopulatedValues(a Range) iter.Seq2[CellRef, Value]
 }
 
 type Value struct {
@@ -65,6 +128,14 @@ func ErrorValue(e error) Value {
 
 }
 
+// BlankValue is the value of an empty cell. It acts as 0 in arithmetic, and as
+// "" in concatenation.
+func BlankValue() Value {
+	return Value{
+		IsA: ValueKindBlank,
+	}
+}
+
 func RangeValue(r Range) Value {
 	return Value{
 		IsA:   ValueKindRange,
@@ -80,4 +151,5 @@ const (
 	ValueKindLogical
 	ValueKindError
 	ValueKindRange
+	ValueKindBlank
 )
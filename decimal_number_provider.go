@@ -0,0 +1,375 @@
+package effe
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode selects how a NumberProvider discards digits it cannot keep
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest value, and ties to an even digit
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest value, and ties away from zero
+	RoundHalfUp
+	// RoundDown truncates towards zero
+	RoundDown
+)
+
+// DefaultDecimalPrecision is used when DecimalNumberProvider.Precision is zero.
+// It matches the 34 significant digits of IEEE 754 decimal128.
+const DefaultDecimalPrecision = 34
+
+// Results with an exponent outside of this range are reported as #NUM!
+const decimalMaxExponent = 9999
+
+// DecimalNumberProvider implements arbitrary precision decimal arithmetic, so
+// literals such as 0.1 are represented exactly, and =0.1+0.2 is exactly 0.3.
+// Every result is rounded to Precision significant digits using Rounding.
+type DecimalNumberProvider struct {
+	Precision uint
+	Rounding  RoundingMode
+}
+
+// decimalNumber has the value coef * 10^exp. Its coef is never modified.
+type decimalNumber struct {
+	coef *big.Int
+	exp  int
+}
+
+var bigTen = big.NewInt(10)
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func decimalDigits(i *big.Int) int {
+	if i.Sign() == 0 {
+		return 1
+	}
+	return len(new(big.Int).Abs(i).Text(10))
+}
+
+func (d decimalNumber) String() string {
+	if d.coef.Sign() == 0 {
+		return "0"
+	}
+	sign := ""
+	if d.coef.Sign() < 0 {
+		sign = "-"
+	}
+	digits := new(big.Int).Abs(d.coef).Text(10)
+	if d.exp >= 0 {
+		return sign + digits + strings.Repeat("0", d.exp)
+	}
+
+	if pad := -d.exp - len(digits) + 1; pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	point := len(digits) + d.exp
+	whole, fraction := digits[:point], strings.TrimRight(digits[point:], "0")
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
+
+func (p DecimalNumberProvider) precision() int {
+	if p.Precision == 0 {
+		return DefaultDecimalPrecision
+	}
+	return int(p.Precision)
+}
+
+// ParseNumber accepts an optional sign, digits with an optional decimal point,
+// and an optional exponent, eg: 12, -0.5, .5, 1.5E-3
+func (p DecimalNumberProvider) ParseNumber(text string) (Number, error) {
+	if !isExcelNumber(text) {
+		return nil, fmt.Errorf("invalid number: %q", text)
+	}
+	mantissa, exp := text, 0
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		e, err := strconv.Atoi(text[i+1:])
+		if err != nil || e > 2*decimalMaxExponent || e < -2*decimalMaxExponent {
+			return nil, ErrNum
+		}
+		mantissa, exp = text[:i], e
+	}
+
+	sign := ""
+	if mantissa[0] == '-' || mantissa[0] == '+' {
+		sign, mantissa = mantissa[:1], mantissa[1:]
+	}
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	coef, _ := new(big.Int).SetString(sign+whole+fraction, 10)
+	return p.round(coef, exp-len(fraction))
+}
+
+// isExcelNumber reports whether text is written the way excel writes numbers:
+// an optional sign, digits with an optional decimal point, and an optional
+// exponent, eg: -0.5 or 1.5E-3, but not 1_0, 0x10 or NaN
+func isExcelNumber(text string) bool {
+	mantissa, exp, hasExp := strings.Cut(strings.ToUpper(text), "E")
+	if hasExp && (trimSign(exp) == "" || !isDigits(trimSign(exp))) {
+		return false
+	}
+	whole, fraction, _ := strings.Cut(trimSign(mantissa), ".")
+	return whole+fraction != "" && isDigits(whole) && isDigits(fraction)
+}
+
+func trimSign(s string) string {
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		return s[1:]
+	}
+	return s
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (p DecimalNumberProvider) FromInt(i int64) Number {
+	n, _ := p.round(big.NewInt(i), 0)
+	return n
+}
+
+// align returns the coefficients of a and b scaled to a common exponent
+func align(a decimalNumber, b decimalNumber) (*big.Int, *big.Int, int) {
+	switch {
+	case a.exp > b.exp:
+		return new(big.Int).Mul(a.coef, pow10(a.exp-b.exp)), b.coef, b.exp
+	case a.exp < b.exp:
+		return a.coef, new(big.Int).Mul(b.coef, pow10(b.exp-a.exp)), a.exp
+	}
+	return a.coef, b.coef, a.exp
+}
+
+func (p DecimalNumberProvider) Add(a Number, b Number) (Number, error) {
+	ac, bc, exp := align(a.(decimalNumber), b.(decimalNumber))
+	return p.round(new(big.Int).Add(ac, bc), exp)
+}
+
+func (p DecimalNumberProvider) Sub(a Number, b Number) (Number, error) {
+	ac, bc, exp := align(a.(decimalNumber), b.(decimalNumber))
+	return p.round(new(big.Int).Sub(ac, bc), exp)
+}
+
+func (p DecimalNumberProvider) Mul(a Number, b Number) (Number, error) {
+	ad := a.(decimalNumber)
+	bd := b.(decimalNumber)
+	return p.round(new(big.Int).Mul(ad.coef, bd.coef), ad.exp+bd.exp)
+}
+
+func (p DecimalNumberProvider) Div(a Number, b Number) (Number, error) {
+	return p.div(a.(decimalNumber), b.(decimalNumber), p.precision())
+}
+
+func (p DecimalNumberProvider) div(a decimalNumber, b decimalNumber, precision int) (Number, error) {
+	if b.coef.Sign() == 0 {
+		return nil, ErrDiv0
+	}
+	// Scale a so the quotient has at least one more digit than we keep, then
+	// append a sticky digit if anything remains, so rounding sees the right side
+	// of the halfway point.
+	shift := precision + decimalDigits(b.coef) - decimalDigits(a.coef) + 1
+	if shift < 0 {
+		shift = 0
+	}
+	q, r := new(big.Int).QuoRem(new(big.Int).Mul(a.coef, pow10(shift)), b.coef, new(big.Int))
+	exp := a.exp - b.exp - shift
+	if r.Sign() != 0 {
+		q.Mul(q, bigTen)
+		if a.coef.Sign() == b.coef.Sign() {
+			q.Add(q, big.NewInt(1))
+		} else {
+			q.Sub(q, big.NewInt(1))
+		}
+		exp--
+	} else {
+		// An exact quotient, drop the zeros introduced by scaling
+		for exp < a.exp-b.exp && new(big.Int).Rem(q, bigTen).Sign() == 0 {
+			q.Quo(q, bigTen)
+			exp++
+		}
+	}
+	return p.roundTo(q, exp, precision)
+}
+
+func (p DecimalNumberProvider) Pow(a Number, b Number) (Number, error) {
+	ad := a.(decimalNumber)
+	bd := b.(decimalNumber)
+	if ad.coef.Sign() == 0 {
+		switch bd.coef.Sign() {
+		case 0:
+			return nil, ErrNum
+		case -1:
+			return nil, ErrDiv0
+		}
+		return ad, nil
+	}
+
+	if n, ok := decimalInteger(bd); ok {
+		return p.powInt(ad, n)
+	}
+	if ad.coef.Sign() < 0 {
+		return nil, ErrNum
+	}
+	// Guard digits, so that only the final result is rounded to precision
+	digits := p.precision() + 10
+	f, ok := fractionalPow(ad.rat(), bd.rat(), digits)
+	if !ok {
+		return nil, ErrNum
+	}
+	return p.ParseNumber(f.Text('e', digits))
+}
+
+// decimalInteger returns the value of d if it is an integer that fits an int64
+func decimalInteger(d decimalNumber) (int64, bool) {
+	i := d.coef
+	if d.exp > 0 {
+		if d.exp > 18 {
+			return 0, false
+		}
+		i = new(big.Int).Mul(i, pow10(d.exp))
+	} else if d.exp < 0 {
+		q, r := new(big.Int).QuoRem(i, pow10(-d.exp), new(big.Int))
+		if r.Sign() != 0 {
+			return 0, false
+		}
+		i = q
+	}
+	return i.Int64(), i.IsInt64()
+}
+
+// powInt uses exponentiation by squaring, carrying guard digits through the
+// intermediate products so only the final result is rounded to precision.
+func (p DecimalNumberProvider) powInt(a decimalNumber, n int64) (Number, error) {
+	guarded := DecimalNumberProvider{Precision: uint(p.precision() + 10), Rounding: p.Rounding}
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	result, err := guarded.powPositive(a, n)
+	if negative {
+		// A power too large to represent has an inverse too small to, and the
+		// other way round, eg: =0.1^-99999999
+		switch {
+		case errors.Is(err, ErrNum):
+			return decimalNumber{coef: new(big.Int)}, nil
+		case err != nil:
+			return nil, err
+		case result.coef.Sign() == 0:
+			return nil, ErrNum
+		}
+		return p.div(decimalNumber{coef: big.NewInt(1)}, result, p.precision())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.round(result.coef, result.exp)
+}
+
+// powPositive raises a to the power n >= 0, rounding every product
+func (p DecimalNumberProvider) powPositive(a decimalNumber, n int64) (decimalNumber, error) {
+	result := decimalNumber{coef: big.NewInt(1)}
+	base := a
+	for n > 0 {
+		if n&1 == 1 {
+			r, err := p.Mul(result, base)
+			if err != nil {
+				return decimalNumber{}, err
+			}
+			result = r.(decimalNumber)
+		}
+		n >>= 1
+		if n > 0 {
+			b, err := p.Mul(base, base)
+			if err != nil {
+				return decimalNumber{}, err
+			}
+			base = b.(decimalNumber)
+		}
+	}
+	return result, nil
+}
+
+// rat returns d as an exact fraction
+func (d decimalNumber) rat() *big.Rat {
+	r := new(big.Rat).SetInt(d.coef)
+	if d.exp > 0 {
+		r.Mul(r, new(big.Rat).SetInt(pow10(d.exp)))
+	} else if d.exp < 0 {
+		r.Quo(r, new(big.Rat).SetInt(pow10(-d.exp)))
+	}
+	return r
+}
+
+func (p DecimalNumberProvider) Neg(a Number) (Number, error) {
+	ad := a.(decimalNumber)
+	return decimalNumber{coef: new(big.Int).Neg(ad.coef), exp: ad.exp}, nil
+}
+
+func (p DecimalNumberProvider) Cmp(a Number, b Number) int {
+	ac, bc, _ := align(a.(decimalNumber), b.(decimalNumber))
+	return ac.Cmp(bc)
+}
+
+func (p DecimalNumberProvider) ToFloat(a Number) float64 {
+	ad := a.(decimalNumber)
+	f, _ := strconv.ParseFloat(ad.coef.Text(10)+"e"+strconv.Itoa(ad.exp), 64)
+	return f
+}
+
+func (p DecimalNumberProvider) ToInt(a Number) (int64, error) {
+	ad := a.(decimalNumber)
+	i := ad.coef
+	if ad.exp > 0 {
+		i = new(big.Int).Mul(i, pow10(ad.exp))
+	} else if ad.exp < 0 {
+		i = new(big.Int).Quo(i, pow10(-ad.exp))
+	}
+	if !i.IsInt64() {
+		return 0, ErrNum
+	}
+	return i.Int64(), nil
+}
+
+func (p DecimalNumberProvider) round(coef *big.Int, exp int) (Number, error) {
+	return p.roundTo(coef, exp, p.precision())
+}
+
+// roundTo rounds coef * 10^exp to precision significant digits
+func (p DecimalNumberProvider) roundTo(coef *big.Int, exp int, precision int) (Number, error) {
+	if coef.Sign() == 0 {
+		return decimalNumber{coef: coef, exp: 0}, nil
+	}
+	digits := decimalDigits(coef)
+	if drop := digits - precision; drop > 0 {
+		q, r := new(big.Int).QuoRem(new(big.Int).Abs(coef), pow10(drop), new(big.Int))
+		half := r.Mul(r, big.NewInt(2)).Cmp(pow10(drop))
+		if (p.Rounding == RoundHalfUp && half >= 0) ||
+			(p.Rounding == RoundHalfEven && (half > 0 || (half == 0 && q.Bit(0) == 1))) {
+			q.Add(q, big.NewInt(1))
+		}
+		if coef.Sign() < 0 {
+			q.Neg(q)
+		}
+		coef, exp, digits = q, exp+drop, decimalDigits(q)
+	}
+
+	if adjusted := exp + digits - 1; adjusted > decimalMaxExponent {
+		return nil, ErrNum
+	} else if adjusted < -decimalMaxExponent {
+		return decimalNumber{coef: new(big.Int), exp: 0}, nil
+	}
+	return decimalNumber{coef: coef, exp: exp}, nil
+}
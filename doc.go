@@ -0,0 +1,13 @@
+// Package effe parses and evaluates excel formulas, eg: =SUM(A1:A10)*2.
+//
+// Parse turns formula text into a tree of Nodes, which Eval computes using the
+// numbers and ranges of a Context, and Format turns back into formula text.
+//
+// # Compatibility
+//
+// The syntax tree is part of the API, so that applications can build their
+// own analyses on it, eg: finding the cells a formula depends on. Within a
+// major version, the accessors of Node and the meaning of existing NodeKind
+// and Operator values will not change. New kinds of node and new operators may
+// be added, so code that switches on them should allow for unknown values.
+package effe
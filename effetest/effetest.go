@@ -0,0 +1,192 @@
+// Package effetest implements support for testing implementations of effe's
+// extension points.
+package effetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JoinCAD/effe"
+)
+
+// TestNumberProvider checks that p implements the semantics effe expects of a
+// NumberProvider: literal parsing, arithmetic identities, sign handling, excel
+// errors for division by zero and overflow, and a consistent ordering.
+//
+// Providers should be configured to keep at least 2 decimal places.
+func TestNumberProvider(t *testing.T, p effe.NumberProvider) {
+	t.Helper()
+	c := checker{p: p}
+
+	t.Run("parse", func(t *testing.T) {
+		c.t = t
+		c.equal("1e5", c.parse("1e5"), p.FromInt(100000))
+		c.equal("1E-2", c.parse("1E-2"), c.div(p.FromInt(1), p.FromInt(100)))
+		c.equal(".5", c.parse(".5"), c.div(p.FromInt(1), p.FromInt(2)))
+		c.equal("007", c.parse("007"), p.FromInt(7))
+		c.equal("1.50", c.parse("1.50"), c.parse("1.5"))
+		c.equal("0.0", c.parse("0.0"), p.FromInt(0))
+		for _, text := range []string{"", ".", "e5", "1e", "1.2.3", "abc", "1,000", "1_0", "0x1p4", "0x10", "NaN", "Inf", "+Inf", "1e+-5"} {
+			if n, err := p.ParseNumber(text); err == nil {
+				t.Errorf("ParseNumber(%q) should fail, but got %v", text, n)
+			}
+		}
+	})
+
+	t.Run("identities", func(t *testing.T) {
+		c.t = t
+		zero, one := p.FromInt(0), p.FromInt(1)
+		for _, text := range []string{"0", "1", "2.5", "12345.25", "0.01"} {
+			a := c.parse(text)
+			c.equal(text+"+0", c.ok(p.Add(a, zero)), a)
+			c.equal("0+"+text, c.ok(p.Add(zero, a)), a)
+			c.equal(text+"-0", c.ok(p.Sub(a, zero)), a)
+			c.equal(text+"-"+text, c.ok(p.Sub(a, a)), zero)
+			c.equal(text+"*1", c.ok(p.Mul(a, one)), a)
+			c.equal(text+"*0", c.ok(p.Mul(a, zero)), zero)
+			c.equal(text+"/1", c.ok(p.Div(a, one)), a)
+			c.equal(text+"^1", c.ok(p.Pow(a, one)), a)
+			c.equal("--"+text, c.ok(p.Neg(c.ok(p.Neg(a)))), a)
+			if text != "0" {
+				c.equal(text+"/"+text, c.div(a, a), one)
+				c.equal(text+"^0", c.ok(p.Pow(a, zero)), one)
+			}
+		}
+		c.equal("2+3", c.ok(p.Add(p.FromInt(2), p.FromInt(3))), p.FromInt(5))
+		c.equal("2^3", c.ok(p.Pow(p.FromInt(2), p.FromInt(3))), p.FromInt(8))
+		c.equal("50/100", c.div(p.FromInt(50), p.FromInt(100)), c.parse("0.5"))
+		c.equal("2^-1", c.ok(p.Pow(p.FromInt(2), p.FromInt(-1))), c.parse("0.5"))
+	})
+
+	t.Run("signs", func(t *testing.T) {
+		c.t = t
+		two, three := p.FromInt(2), p.FromInt(3)
+		minusTwo, minusThree := p.FromInt(-2), p.FromInt(-3)
+		c.equal("-(2)", c.ok(p.Neg(two)), minusTwo)
+		c.equal("2-3", c.ok(p.Sub(two, three)), p.FromInt(-1))
+		c.equal("-2*-3", c.ok(p.Mul(minusTwo, minusThree)), p.FromInt(6))
+		c.equal("-2*3", c.ok(p.Mul(minusTwo, three)), p.FromInt(-6))
+		c.equal("-6/3", c.div(p.FromInt(-6), three), minusTwo)
+		c.equal("6/-3", c.div(p.FromInt(6), minusThree), minusTwo)
+		c.equal("(-2)^2", c.ok(p.Pow(minusTwo, two)), p.FromInt(4))
+		c.equal("(-2)^3", c.ok(p.Pow(minusTwo, three)), p.FromInt(-8))
+		for name, n := range map[string]effe.Number{
+			"-(0)":  c.ok(p.Neg(p.FromInt(0))),
+			"-2*0":  c.ok(p.Mul(minusTwo, p.FromInt(0))),
+			"-2--2": c.ok(p.Sub(minusTwo, minusTwo)),
+		} {
+			if n != nil && n.String() != "0" {
+				t.Errorf("%v should render as 0, but got %v", name, n)
+			}
+		}
+		if i, err := p.ToInt(c.parse("2.75")); err != nil || i != 2 {
+			t.Errorf("ToInt(2.75) should be 2, but got %v %v", i, err)
+		}
+		if i, err := p.ToInt(c.ok(p.Neg(c.parse("2.75")))); err != nil || i != -2 {
+			t.Errorf("ToInt(-2.75) should be -2, but got %v %v", i, err)
+		}
+		if f := p.ToFloat(c.parse("-0.25")); f != -0.25 {
+			t.Errorf("ToFloat(-0.25) should be -0.25, but got %v", f)
+		}
+	})
+
+	t.Run("division by zero", func(t *testing.T) {
+		c.t = t
+		for _, text := range []string{"0", "1", "2.5"} {
+			if n, err := p.Div(c.parse(text), p.FromInt(0)); !errors.Is(err, effe.ErrDiv0) {
+				t.Errorf("%v/0 should be #DIV/0!, but got %v %v", text, n, err)
+			}
+		}
+		if n, err := p.Pow(p.FromInt(0), p.FromInt(-1)); !errors.Is(err, effe.ErrDiv0) {
+			t.Errorf("0^-1 should be #DIV/0!, but got %v %v", n, err)
+		}
+		if n, err := p.Pow(p.FromInt(0), p.FromInt(0)); !errors.Is(err, effe.ErrNum) {
+			t.Errorf("0^0 should be #NUM!, but got %v %v", n, err)
+		}
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		c.t = t
+		// Squaring grows without bound, and must eventually fail with #NUM!
+		// rather than wrapping around.
+		zero := p.FromInt(0)
+		for _, start := range []effe.Number{p.FromInt(1000000000), p.FromInt(-1000000000)} {
+			n := start
+			var err error
+			for i := 0; i < 64 && err == nil; i++ {
+				var next effe.Number
+				if next, err = p.Mul(n, n); err == nil {
+					if p.Cmp(next, zero) <= 0 || p.Cmp(next, n) <= 0 && p.Cmp(n, zero) > 0 {
+						t.Fatalf("%v squared wrapped around to %v", n, next)
+					}
+					n = next
+				}
+			}
+			if !errors.Is(err, effe.ErrNum) {
+				t.Errorf("Repeated squaring of %v should fail with #NUM!, but got %v %v", start, n, err)
+			}
+		}
+	})
+
+	t.Run("ordering", func(t *testing.T) {
+		c.t = t
+		ordered := []effe.Number{
+			c.ok(p.Neg(c.parse("1e3"))),
+			p.FromInt(-2),
+			c.ok(p.Neg(c.parse(".5"))),
+			p.FromInt(0),
+			c.parse("0.25"),
+			p.FromInt(1),
+			c.parse("1.01"),
+			p.FromInt(10),
+			c.parse("1e3"),
+		}
+		for i, a := range ordered {
+			for j, b := range ordered {
+				expected := 0
+				if i < j {
+					expected = -1
+				} else if i > j {
+					expected = 1
+				}
+				if actual := p.Cmp(a, b); actual != expected {
+					t.Errorf("Cmp(%v, %v) should be %v, but got %v", a, b, expected, actual)
+				}
+			}
+		}
+	})
+}
+
+type checker struct {
+	t *testing.T
+	p effe.NumberProvider
+}
+
+func (c checker) parse(text string) effe.Number {
+	c.t.Helper()
+	n, err := c.p.ParseNumber(text)
+	if err != nil {
+		c.t.Fatalf("ParseNumber(%q) failed: %v", text, err)
+	}
+	return n
+}
+
+func (c checker) ok(n effe.Number, err error) effe.Number {
+	c.t.Helper()
+	if err != nil {
+		c.t.Fatalf("Unexpected error: %v", err)
+	}
+	return n
+}
+
+func (c checker) div(a effe.Number, b effe.Number) effe.Number {
+	c.t.Helper()
+	return c.ok(c.p.Div(a, b))
+}
+
+func (c checker) equal(name string, actual effe.Number, expected effe.Number) {
+	c.t.Helper()
+	if c.p.Cmp(actual, expected) != 0 {
+		c.t.Errorf("%v should be %v, but got %v", name, expected, actual)
+	}
+}
@@ -0,0 +1,40 @@
+package effetest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JoinCAD/effe"
+)
+
+func TestBuiltinNumberProviders(t *testing.T) {
+	providers := map[string]effe.NumberProvider{
+		"float64":            effe.Float64NumberProvider{},
+		"float64 excel":      effe.Float64NumberProvider{ExcelCompatible: true},
+		"decimal":            effe.DecimalNumberProvider{},
+		"decimal half up":    effe.DecimalNumberProvider{Precision: 10, Rounding: effe.RoundHalfUp},
+		"rational":           effe.RationalNumberProvider{},
+		"rational fractions": effe.RationalNumberProvider{Fractions: true},
+		"fixed point":        effe.FixedPointNumberProvider{Scale: 2},
+		"fixed point down":   effe.FixedPointNumberProvider{Scale: 4, Rounding: effe.RoundDown},
+		"fixed point micros": effe.FixedPointNumberProvider{Scale: 6},
+	}
+	for name, p := range providers {
+		t.Run(name, func(t *testing.T) {
+			TestNumberProvider(t, p)
+		})
+	}
+}
+
+// TestFixedPointLargestScale checks that % works at the largest scale, even
+// though the full suite needs more integer digits than that scale leaves
+func TestFixedPointLargestScale(t *testing.T) {
+	ctx := effe.Context{Numbers: effe.FixedPointNumberProvider{Scale: effe.MaxFixedPointScale}}
+	n, errors, err := effe.Parse(strings.NewReader("=50%"), ctx)
+	if err != nil || len(errors) != 0 {
+		t.Fatalf("Got parse errors: %v %v", err, errors)
+	}
+	if v := effe.Eval(n, ctx); v.IsA != effe.ValueKindNumber || v.Number.String() != "0.5" {
+		t.Errorf("=50%% should be 0.5, but got %v", v)
+	}
+}
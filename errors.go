@@ -0,0 +1,123 @@
+package effe
+
+import "errors"
+
+// ErrorCode identifies an excel error value. The codes match those returned by
+// excel's ERROR.TYPE, eg: #DIV/0! is 2.
+type ErrorCode int
+
+const (
+	ErrorCodeNull        ErrorCode = 1
+	ErrorCodeDiv0        ErrorCode = 2
+	ErrorCodeValue       ErrorCode = 3
+	ErrorCodeRef         ErrorCode = 4
+	ErrorCodeName        ErrorCode = 5
+	ErrorCodeNum         ErrorCode = 6
+	ErrorCodeNA          ErrorCode = 7
+	ErrorCodeGettingData ErrorCode = 8
+	ErrorCodeSpill       ErrorCode = 9
+	ErrorCodeCalc        ErrorCode = 14
+)
+
+var errorCodeText = map[ErrorCode]string{
+	ErrorCodeNull:        "#NULL!",
+	ErrorCodeDiv0:        "#DIV/0!",
+	ErrorCodeValue:       "#VALUE!",
+	ErrorCodeRef:         "#REF!",
+	ErrorCodeName:        "#NAME?",
+	ErrorCodeNum:         "#NUM!",
+	ErrorCodeNA:          "#N/A",
+	ErrorCodeGettingData: "#GETTING_DATA",
+	ErrorCodeSpill:       "#SPILL!",
+	ErrorCodeCalc:        "#CALC!",
+}
+
+// String returns the text excel displays for the error, eg: #N/A
+func (c ErrorCode) String() string {
+	if s, ok := errorCodeText[c]; ok {
+		return s
+	}
+	return "#UNKNOWN!"
+}
+
+// ExcelError is an excel error value, as surfaced in Value.Error. Cause
+// optionally records the Go error that led to it, for diagnostics.
+//
+// Errors match by Code, so errors.Is(err, ErrDiv0) holds for any #DIV/0!,
+// whatever its Cause.
+type ExcelError struct {
+	Code  ErrorCode
+	Cause error
+}
+
+// Excel error values without a cause
+var (
+	ErrNull        = &ExcelError{Code: ErrorCodeNull}
+	ErrDiv0        = &ExcelError{Code: ErrorCodeDiv0}
+	ErrValue       = &ExcelError{Code: ErrorCodeValue}
+	ErrRef         = &ExcelError{Code: ErrorCodeRef}
+	ErrName        = &ExcelError{Code: ErrorCodeName}
+	ErrNum         = &ExcelError{Code: ErrorCodeNum}
+	ErrNA          = &ExcelError{Code: ErrorCodeNA}
+	ErrGettingData = &ExcelError{Code: ErrorCodeGettingData}
+	ErrSpill       = &ExcelError{Code: ErrorCodeSpill}
+	ErrCalc        = &ExcelError{Code: ErrorCodeCalc}
+)
+
+// excelErrors are the errors that can be written as literals, eg: =IF(A1,#N/A)
+var excelErrors = []*ExcelError{
+	ErrNull, ErrDiv0, ErrValue, ErrRef, ErrName, ErrNum, ErrNA, ErrGettingData, ErrSpill, ErrCalc,
+}
+
+// String returns the text excel displays for the error, eg: #DIV/0!
+func (e *ExcelError) String() string {
+	return e.Code.String()
+}
+
+// Error includes the cause, if any, eg: #VALUE!: invalid number: "abc"
+func (e *ExcelError) Error() string {
+	if e.Cause == nil {
+		return e.Code.String()
+	}
+	return e.Code.String() + ": " + e.Cause.Error()
+}
+
+func (e *ExcelError) Unwrap() error {
+	return e.Cause
+}
+
+func (e *ExcelError) Is(target error) bool {
+	t, ok := target.(*ExcelError)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns an error with the same code as e, caused by cause
+func (e *ExcelError) Wrap(cause error) *ExcelError {
+	return &ExcelError{Code: e.Code, Cause: cause}
+}
+
+// SpanError locates an error in the text of a formula. Eval wraps errors in a
+// SpanError for the node they arose at, eg: the #DIV/0! from =1+A1/0 spans
+// A1/0, while errors.Is and AsExcelError still see the error inside.
+type SpanError struct {
+	Span Span
+	Err  error
+}
+
+func (e *SpanError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SpanError) Unwrap() error {
+	return e.Err
+}
+
+// AsExcelError finds the ExcelError in err's chain. Any other error is treated
+// as #VALUE!, caused by err.
+func AsExcelError(err error) *ExcelError {
+	var e *ExcelError
+	if errors.As(err, &e) {
+		return e
+	}
+	return ErrValue.Wrap(err)
+}
@@ -0,0 +1,33 @@
+package effe
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestExcelErrorIs(t *testing.T) {
+	cause := errors.New("cause")
+	err := ErrDiv0.Wrap(cause)
+	if !errors.Is(err, ErrDiv0) {
+		t.Errorf("Expected %v to be #DIV/0!", err)
+	}
+	if errors.Is(err, ErrNA) {
+		t.Errorf("Expected %v not to be #N/A", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("Expected %v to wrap its cause", err)
+	}
+	if err.String() != "#DIV/0!" || err.Error() != "#DIV/0!: cause" {
+		t.Errorf("Unexpected text %q %q", err.String(), err.Error())
+	}
+}
+
+func TestAsExcelError(t *testing.T) {
+	if e := AsExcelError(ErrNum.Wrap(strconv.ErrRange)); e.Code != ErrorCodeNum {
+		t.Errorf("Expected #NUM!, but got %v", e)
+	}
+	if e := AsExcelError(strconv.ErrSyntax); e.Code != ErrorCodeValue || !errors.Is(e, strconv.ErrSyntax) {
+		t.Errorf("Expected #VALUE! caused by %v, but got %v", strconv.ErrSyntax, e)
+	}
+}
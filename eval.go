@@ -1 +1,214 @@
 package effe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Eval computes the value of a parsed formula. Arithmetic is delegated to
+// ctx.Numbers, and references are resolved through ctx.Ranges. An error is
+// wrapped in a SpanError for the node it arose at.
+func Eval(n *Node, ctx Context) Value {
+	if _, ok := ctx.Ranges.(implicitIntersector); !ok && ctx.Cell != (CellRef{}) {
+		ctx.Ranges = implicitIntersector{rp: ctx.Ranges, target: ctx.Ranges.ParseRange(ctx.Cell.String())}
+	}
+	v := eval(n, ctx)
+	if v.IsA != ValueKindError {
+		return v
+	}
+	var located *SpanError
+	if errors.As(v.Error, &located) {
+		return v
+	}
+	ctx.debug("eval error", "error", v.Error, "node", n.kind, "text", n.rawValue, "span", n.span)
+	return ErrorValue(&SpanError{Span: n.span, Err: v.Error})
+}
+
+func eval(n *Node, ctx Context) Value {
+	switch n.kind {
+	case NodeKindLiteral, NodeKindReference:
+		return n.literalValue
+	case NodeKindHole:
+		// An omitted argument, eg: the second argument in =IF(A1,,3)
+		return BlankValue()
+	case NodeKindOperator:
+		return evalOperator(n, ctx)
+	case NodeKindFunction:
+		return evalFunction(n, ctx)
+	}
+	return ErrorValue(fmt.Errorf("unexpected node kind: %v", n.kind))
+}
+
+func evalOperator(n *Node, ctx Context) Value {
+	args := make([]Value, len(n.children))
+	for i, c := range n.children {
+		args[i] = Eval(c, ctx)
+	}
+
+	switch n.operatorValue {
+	case Intersection:
+		return intersect(ctx, args[0], args[1])
+	case UnaryPlus:
+		// Unlike -, + does not even convert its operand, eg: =+"a" is "a"
+		return args[0]
+	}
+
+	// Every other operator works on single values, and the leftmost error wins.
+	for i := range args {
+		args[i] = args[i].ToScalar(ctx)
+		if args[i].IsA == ValueKindError {
+			return args[i]
+		}
+	}
+
+	switch n.operatorValue {
+	case Concatenation:
+		a, _ := args[0].ToText(ctx)
+		b, _ := args[1].ToText(ctx)
+		return TextValue(a + b)
+	case Equality, Inequality, GreaterThan, LessThan, GreaterThanOrEqual, LessThanOrEqual:
+		return compare(ctx, n.operatorValue, args[0], args[1])
+	}
+
+	nums := make([]Number, len(args))
+	for i, a := range args {
+		num, err := a.ToNumber(ctx)
+		if err != nil {
+			return ErrorValue(err)
+		}
+		nums[i] = num
+	}
+	result, err := arithmetic(ctx.Numbers, n.operatorValue, nums)
+	if err != nil {
+		return ErrorValue(err)
+	}
+	return NumberValue(result)
+}
+
+// fromInt converts i, or reports #NUM! if np cannot represent it, eg: when a
+// FixedPointNumberProvider saturates
+func fromInt(np NumberProvider, i int64) (Number, error) {
+	n := np.FromInt(i)
+	if j, err := np.ToInt(n); err != nil || j != i {
+		return nil, ErrNum
+	}
+	return n, nil
+}
+
+func arithmetic(np NumberProvider, o Operator, args []Number) (Number, error) {
+	switch o {
+	case UnaryNegation:
+		return np.Neg(args[0])
+	case Percent:
+		hundred, err := fromInt(np, 100)
+		if err != nil {
+			return nil, err
+		}
+		return np.Div(args[0], hundred)
+	case Exponentiation:
+		return np.Pow(args[0], args[1])
+	case Multiplication:
+		return np.Mul(args[0], args[1])
+	case Division:
+		return np.Div(args[0], args[1])
+	case Addition:
+		return np.Add(args[0], args[1])
+	case Subtraction:
+		return np.Sub(args[0], args[1])
+	}
+	return nil, fmt.Errorf("unexpected arithmetic operator: %v", o)
+}
+
+func intersect(ctx Context, a Value, b Value) Value {
+	if a.IsA == ValueKindError {
+		return a
+	}
+	if b.IsA == ValueKindError {
+		return b
+	}
+	if a.IsA != ValueKindRange || b.IsA != ValueKindRange {
+		return ErrorValue(ErrValue)
+	}
+	return RangeValue(ctx.Ranges.Intersect(a.Range, b.Range))
+}
+
+// compare orders values the way excel does: numbers sort before text, which
+// sorts before logicals, and text is compared without regard to case. A blank
+// compares as 0, "" or FALSE, matching the other side.
+func compare(ctx Context, o Operator, a Value, b Value) Value {
+	a = blankAs(ctx, a, b)
+	b = blankAs(ctx, b, a)
+	var c int
+	ra, rb := compareRank(a), compareRank(b)
+	switch {
+	case ra != rb:
+		c = ra - rb
+	case a.IsA == ValueKindNumber:
+		c = ctx.Numbers.Cmp(a.Number, b.Number)
+	case a.IsA == ValueKindText:
+		c = strings.Compare(strings.ToUpper(a.Text), strings.ToUpper(b.Text))
+	default:
+		c = logicalRank(a.Logical) - logicalRank(b.Logical)
+	}
+
+	switch o {
+	case Equality:
+		return LogicalValue(c == 0)
+	case Inequality:
+		return LogicalValue(c != 0)
+	case GreaterThan:
+		return LogicalValue(c > 0)
+	case LessThan:
+		return LogicalValue(c < 0)
+	case GreaterThanOrEqual:
+		return LogicalValue(c >= 0)
+	default:
+		return LogicalValue(c <= 0)
+	}
+}
+
+// blankAs replaces a blank v with the empty value of the same kind as other
+func blankAs(ctx Context, v Value, other Value) Value {
+	if v.IsA != ValueKindBlank {
+		return v
+	}
+	switch other.IsA {
+	case ValueKindText:
+		return TextValue("")
+	case ValueKindLogical:
+		return LogicalValue(false)
+	}
+	return NumberValue(ctx.Numbers.FromInt(0))
+}
+
+func compareRank(v Value) int {
+	switch v.IsA {
+	case ValueKindNumber:
+		return 0
+	case ValueKindText:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func logicalRank(l bool) int {
+	if l {
+		return 1
+	}
+	return 0
+}
+
+func evalFunction(n *Node, ctx Context) Value {
+	f, ok := formulas[strings.ToUpper(n.rawValue)]
+	if !ok {
+		return ErrorValue(ErrName)
+	}
+
+	args := make([]Value, len(n.children))
+	for i, c := range n.children {
+		args[i] = Eval(c, ctx)
+	}
+	return f(ctx, args)
+}
@@ -1,6 +1,10 @@
 package effe
 
 import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -25,6 +29,23 @@ import (
 // 	return nil
 // }
 
+var testContext = Context{
+	Numbers: Float64NumberProvider{},
+	Ranges:  testRanges(),
+}
+
+// testRanges holds 10*column + row in each cell of A1:C3, and text in D1
+func testRanges() *MemoryRangeProvider {
+	m := NewMemoryRangeProvider()
+	for c, column := range []string{"A", "B", "C"} {
+		for r := 1; r <= 3; r++ {
+			m.Set(column+strconv.Itoa(r), NumberValue(Float64NumberProvider{}.FromInt(int64(10*(c+1)+r))))
+		}
+	}
+	m.Set("D1", TextValue("ignored"))
+	return m
+}
+
 type tokenizeTestCase struct {
 	name     string
 	cell     string
@@ -48,12 +69,69 @@ var tokenCases []tokenizeTestCase = []tokenizeTestCase{
 			if len(ts) != 1 {
 				t.Errorf("Expected length 1, but got: %v", ts)
 			}
-			if ts[0] != (token{value: "A1", typ: TokenTypeRange}) {
+			if ts[0] != (token{value: "A1", typ: TokenTypeRange, span: asciiSpan(1, 3)}) {
 				t.Errorf("Expected a range, but got %v", ts[0])
 
 			}
 		},
 	},
+	tokenizeTestCase{
+		name: "absolute reference",
+		cell: "=$A$1*B$2:$C3",
+		validate: func(t *testing.T, ts []token) {
+			if len(ts) != 3 {
+				t.Fatalf("Expected length 3, but got: %v", ts)
+			}
+			if ts[0] != (token{value: "$A$1", typ: TokenTypeRange, span: asciiSpan(1, 5)}) {
+				t.Errorf("Expected a range, but got %v", ts[0])
+			}
+			if ts[2] != (token{value: "B$2:$C3", typ: TokenTypeRange, span: asciiSpan(6, 13)}) {
+				t.Errorf("Expected a range, but got %v", ts[2])
+			}
+		},
+	},
+	tokenizeTestCase{
+		name: "row range",
+		cell: "=$1:3",
+		validate: func(t *testing.T, ts []token) {
+			if len(ts) != 1 || ts[0] != (token{value: "$1:3", typ: TokenTypeRange, span: asciiSpan(1, 5)}) {
+				t.Errorf("Expected a range, but got %v", ts)
+			}
+		},
+	},
+	tokenizeTestCase{
+		name: "text",
+		cell: `=SUM("hello ""world""", "")`,
+		validate: func(t *testing.T, ts []token) {
+			if len(ts) != 6 {
+				t.Fatalf("Expected length 6, but got: %v", ts)
+			}
+			if ts[2] != (token{value: `hello "world"`, typ: TokenTypeText, span: asciiSpan(5, 22)}) {
+				t.Errorf("Expected text, but got %v", ts[2])
+			}
+			if ts[4] != (token{value: "", typ: TokenTypeText, span: asciiSpan(24, 26)}) {
+				t.Errorf("Expected empty text, but got %v", ts[4])
+			}
+		},
+	},
+	tokenizeTestCase{
+		name: "logicals and errors",
+		cell: "=IF(true,#div/0!,FALSE)",
+		validate: func(t *testing.T, ts []token) {
+			if len(ts) != 8 {
+				t.Fatalf("Expected length 8, but got: %v", ts)
+			}
+			if ts[2] != (token{value: "TRUE", typ: TokenTypeLogical, span: asciiSpan(4, 8)}) {
+				t.Errorf("Expected a logical, but got %v", ts[2])
+			}
+			if ts[4] != (token{value: "#DIV/0!", typ: TokenTypeError, span: asciiSpan(9, 16)}) {
+				t.Errorf("Expected an error, but got %v", ts[4])
+			}
+			if ts[6] != (token{value: "FALSE", typ: TokenTypeLogical, span: asciiSpan(17, 22)}) {
+				t.Errorf("Expected a logical, but got %v", ts[6])
+			}
+		},
+	},
 	tokenizeTestCase{
 		name: "sum column",
 		cell: "=sum(A:A) + 2.0",
@@ -65,10 +143,15 @@ var tokenCases []tokenizeTestCase = []tokenizeTestCase{
 	},
 }
 
+// asciiSpan is the span from start to end of text without multi-byte runes
+func asciiSpan(start int, end int) Span {
+	return Span{Position{start, start}, Position{end, end}}
+}
+
 func TestTokenize(t *testing.T) {
 	for _, c := range tokenCases {
 		t.Run(c.name, func(t *testing.T) {
-			tokenizer := newParser(strings.NewReader(c.cell))
+			tokenizer := newParser(strings.NewReader(c.cell), Context{})
 			tokenizer.scanCell()
 			if len(tokenizer.parseErrors) != 0 {
 				t.Errorf("Got parse errors: %v", tokenizer.parseErrors)
@@ -81,10 +164,10 @@ func TestTokenize(t *testing.T) {
 type parseTestCase struct {
 	name     string
 	cell     string
-	validate func(t *testing.T, n *node, pe []parseError)
+	validate func(t *testing.T, n *Node, pe []ParseError)
 }
 
-func assertNodeEqual(t *testing.T, n *node, k nodeKind, v string) {
+func assertNodeEqual(t *testing.T, n *Node, k NodeKind, v string) {
 	if n.kind != k {
 		t.Errorf("Expected node kind %v, but got '%v'", k, n.kind)
 	}
@@ -93,7 +176,7 @@ func assertNodeEqual(t *testing.T, n *node, k nodeKind, v string) {
 	}
 }
 
-func assertNodeOperator(t *testing.T, n *node, o operator) {
+func assertNodeOperator(t *testing.T, n *Node, o Operator) {
 	if n.kind != NodeKindOperator {
 		t.Errorf("Expected node kind Operator, but got %v", n.kind)
 	}
@@ -106,19 +189,280 @@ var parseCases []parseTestCase = []parseTestCase{
 	parseTestCase{
 		name: "sum column",
 		cell: "=sum(A:A) + 2.0",
-		validate: func(t *testing.T, n *node, pe []parseError) {
+		validate: func(t *testing.T, n *Node, pe []ParseError) {
 			assertNodeOperator(t, n, Addition)
 			assertNodeEqual(t, n.children[1], NodeKindLiteral, "2.0")
 			assertNodeEqual(t, n.children[0], NodeKindFunction, "sum")
-			assertNodeEqual(t, n.children[0].children[0], NodeKindLiteral, "A:A")
+			assertNodeEqual(t, n.children[0].children[0], NodeKindReference, "A:A")
+		},
+	},
+	parseTestCase{
+		name: "absolute reference",
+		cell: "=$A$1*B2",
+		validate: func(t *testing.T, n *Node, pe []ParseError) {
+			assertNodeOperator(t, n, Multiplication)
+			assertNodeEqual(t, n.children[0], NodeKindReference, "$A$1")
+			expected := CellRef{Column: 1, Row: 1, AbsoluteColumn: true, AbsoluteRow: true}
+			if n.children[0].area != (Area{expected, expected}) {
+				t.Errorf("Expected an absolute reference, but got %v", n.children[0].area)
+			}
+		},
+	},
+	parseTestCase{
+		name: "intersection",
+		cell: "=A:A 2:2",
+		validate: func(t *testing.T, n *Node, pe []ParseError) {
+			assertNodeOperator(t, n, Intersection)
+			assertNodeEqual(t, n.children[0], NodeKindReference, "A:A")
+			assertNodeEqual(t, n.children[1], NodeKindReference, "2:2")
+		},
+	},
+	parseTestCase{
+		name: "unary plus",
+		cell: "=+1",
+		validate: func(t *testing.T, n *Node, pe []ParseError) {
+			assertNodeOperator(t, n, UnaryPlus)
+			assertNodeEqual(t, n.children[0], NodeKindLiteral, "1")
+		},
+	},
+	parseTestCase{
+		name: "unary plus before addition",
+		cell: "=+A1+B1",
+		validate: func(t *testing.T, n *Node, pe []ParseError) {
+			if len(pe) != 0 {
+				t.Fatalf("Got parse errors: %v", pe)
+			}
+			assertNodeOperator(t, n, Addition)
+			assertNodeOperator(t, n.children[0], UnaryPlus)
+			assertNodeEqual(t, n.children[0].children[0], NodeKindReference, "A1")
+			assertNodeEqual(t, n.children[1], NodeKindReference, "B1")
+		},
+	},
+	parseTestCase{
+		name: "empty arguments",
+		cell: "=IF(A1,,3)",
+		validate: func(t *testing.T, n *Node, pe []ParseError) {
+			assertNodeEqual(t, n, NodeKindFunction, "IF")
+			if len(n.children) != 3 {
+				t.Fatalf("Expected 3 arguments, but got %v", len(n.children))
+			}
+			assertNodeEqual(t, n.children[0], NodeKindReference, "A1")
+			assertNodeEqual(t, n.children[1], NodeKindHole, "")
+			assertNodeEqual(t, n.children[2], NodeKindLiteral, "3")
 		},
 	},
+	parseTestCase{
+		name: "leading empty argument",
+		cell: "=1+sum(,B2)",
+		validate: func(t *testing.T, n *Node, pe []ParseError) {
+			assertNodeOperator(t, n, Addition)
+			sum := n.children[1]
+			assertNodeEqual(t, sum, NodeKindFunction, "sum")
+			if len(sum.children) != 2 {
+				t.Fatalf("Expected 2 arguments, but got %v", len(sum.children))
+			}
+			assertNodeEqual(t, sum.children[0], NodeKindHole, "")
+			assertNodeEqual(t, sum.children[1], NodeKindReference, "B2")
+		},
+	},
+	parseTestCase{
+		name: "no arguments",
+		cell: "=SUM()",
+		validate: func(t *testing.T, n *Node, pe []ParseError) {
+			assertNodeEqual(t, n, NodeKindFunction, "SUM")
+			if len(n.children) != 0 {
+				t.Errorf("Expected no arguments, but got %v", len(n.children))
+			}
+		},
+	},
+}
+
+func TestParseUnterminatedText(t *testing.T) {
+	_, errors, _ := Parse(strings.NewReader(`=1.5+"abc`), testContext)
+	if len(errors) != 1 || errors[0].Span != asciiSpan(5, 9) {
+		t.Errorf("Expected an error from 5 to 9, but got %v", errors)
+	}
+}
+
+func TestParseUnknownError(t *testing.T) {
+	tokenizer := newParser(strings.NewReader("=1.5+#DIV/9!"), testContext)
+	tokenizer.scanCell()
+	if len(tokenizer.parseErrors) != 1 || tokenizer.parseErrors[0].Span.Start.Rune != 5 {
+		t.Errorf("Expected an error at 5, but got %v", tokenizer.parseErrors)
+	}
+}
+
+func TestParseInvalidNumber(t *testing.T) {
+	for _, cell := range []string{"=1E+", "=1e", "=."} {
+		if _, errors, _ := Parse(strings.NewReader(cell), testContext); len(errors) == 0 {
+			t.Errorf("Expected %v to be invalid", cell)
+		}
+	}
+}
+
+func TestParseOutOfRangeNumber(t *testing.T) {
+	ctx := Context{Numbers: DecimalNumberProvider{}}
+	n, errors, _ := Parse(strings.NewReader("=1E-20001"), ctx)
+	if len(errors) != 1 {
+		t.Fatalf("Expected an error, but got %v", errors)
+	}
+	if v := Eval(n, ctx); AsExcelError(v.Error).Code != ErrorCodeNum {
+		t.Errorf("Expected #NUM!, but got %v", v)
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	n, errors, _ := Parse(strings.NewReader(`="é€"&SUM(B2)+1.5`), testContext)
+	if len(errors) != 0 {
+		t.Fatalf("Got parse errors: %v", errors)
+	}
+	expected := []struct {
+		text       string
+		start, end Position
+	}{
+		{"", Position{1, 1}, Position{20, 17}},
+		{`"é€"`, Position{1, 1}, Position{8, 5}},
+		{"", Position{9, 6}, Position{20, 17}},
+		{"SUM", Position{9, 6}, Position{16, 13}},
+		{"B2", Position{13, 10}, Position{15, 12}},
+		{"1.5", Position{17, 14}, Position{20, 17}},
+	}
+	var spans []*Node
+	Inspect(n, func(n *Node) bool {
+		spans = append(spans, n)
+		return true
+	})
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %v nodes, but got %v", len(expected), len(spans))
+	}
+	for i, e := range expected {
+		if spans[i].Text() != e.text || spans[i].Span() != (Span{e.start, e.end}) {
+			t.Errorf("Expected %q from %v to %v, but got %q %v", e.text, e.start, e.end, spans[i].Text(), spans[i].Span())
+		}
+	}
+}
+
+func TestParseCallSpans(t *testing.T) {
+	n, errors, _ := Parse(strings.NewReader("=(1+2)*SUM(A1,,(B2))"), testContext)
+	if len(errors) != 0 {
+		t.Fatalf("Got parse errors: %v", errors)
+	}
+	if n.Span() != asciiSpan(1, 20) {
+		t.Errorf("Expected the formula from 1 to 20, but got %v", n.Span())
+	}
+	if s := n.children[0].Span(); s != asciiSpan(1, 6) {
+		t.Errorf("Expected (1+2) from 1 to 6, but got %v", s)
+	}
+	sum := n.children[1]
+	if sum.Span() != asciiSpan(7, 20) {
+		t.Errorf("Expected SUM from 7 to 20, but got %v", sum.Span())
+	}
+	if s := sum.children[1].Span(); s != asciiSpan(14, 14) {
+		t.Errorf("Expected an empty argument at 14, but got %v", s)
+	}
+	if s := sum.children[2].Span(); s != asciiSpan(15, 19) {
+		t.Errorf("Expected (B2) from 15 to 19, but got %v", s)
+	}
+}
+
+func TestParseErrorSpan(t *testing.T) {
+	_, errors, _ := Parse(strings.NewReader("=A1+1E+"), testContext)
+	if len(errors) != 1 || errors[0].Span != asciiSpan(4, 7) {
+		t.Errorf("Expected an error from 4 to 7, but got %v", errors)
+	}
+}
+
+func TestEvalErrorSpan(t *testing.T) {
+	n, _, _ := Parse(strings.NewReader("=1+A1/0&B2"), testContext)
+	v := Eval(n, testContext)
+	var located *SpanError
+	if !errors.As(v.Error, &located) || located.Span != asciiSpan(3, 7) || !errors.Is(v.Error, ErrDiv0) {
+		t.Errorf("Expected #DIV/0! from 3 to 7, but got %v", v)
+	}
+}
+
+var invalidCells []string = []string{
+	"=",
+	"=1+",
+	"=)",
+	"=(1",
+	"=SUM(1",
+	"=1,2",
+	"=1 2",
+	"=@A1",
+	"=#",
+	"=nosuchname",
+	"=SUM(1))",
+	"=()",
+	"=(1,2)",
+	"=SUM(1+)",
+	"=SUM(1,(2)",
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, cell := range invalidCells {
+		if _, errors, _ := Parse(strings.NewReader(cell), testContext); len(errors) == 0 {
+			t.Errorf("Expected %v to be invalid", cell)
+		}
+	}
+}
+
+// FuzzParse checks that no cell panics the parser, and that every cell results
+// in either a syntax tree or errors that lie within the cell
+func FuzzParse(f *testing.F) {
+	for _, cell := range append(formatCases, invalidCells...) {
+		f.Add(cell)
+	}
+	f.Fuzz(func(t *testing.T, cell string) {
+		n, errors, err := Parse(strings.NewReader(cell), testContext)
+		if (err == nil) != (len(errors) == 0) {
+			t.Fatalf("Got error %v for parse errors %v", err, errors)
+		}
+		for _, e := range errors {
+			if e.Span.Start.Byte > e.Span.End.Byte || e.Span.End.Byte > len(cell) || e.Span.Start.Rune > e.Span.End.Rune {
+				t.Errorf("Expected %v to lie within %q", e.Span, cell)
+			}
+		}
+		if n == nil {
+			if len(errors) == 0 {
+				t.Fatalf("Got neither a syntax tree nor errors for %q", cell)
+			}
+			return
+		}
+		Format(n)
+		Eval(n, testContext)
+	})
+}
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	ctx := testContext
+	ctx.Logger = slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	n, _, _ := Parse(strings.NewReader("=SUM(A1)/0+#"), ctx)
+	Eval(n, ctx)
+	for _, expected := range []string{
+		"msg=token value=SUM type=Function span.start=1 span.end=4",
+		"msg=function name=SUM args=1",
+		`msg="parse error" message="unknown error: #" span.start=11 span.end=12`,
+		`msg="eval error" error=#DIV/0! node=NodeKindOperator text="" span.start=1`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in the log, but got:\n%v", expected, out.String())
+		}
+	}
+
+	out.Reset()
+	ctx.Logger = slog.New(slog.NewTextHandler(&out, nil))
+	Parse(strings.NewReader("=SUM(A1)"), ctx)
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing to be logged above debug level, but got %v", out.String())
+	}
 }
 
 func TestParse(t *testing.T) {
 	for _, c := range parseCases {
 		t.Run(c.name, func(t *testing.T) {
-			node, errors, err := Parse(strings.NewReader(c.cell))
+			node, errors, err := Parse(strings.NewReader(c.cell), testContext)
 			if err != nil {
 				t.Errorf("Got error: %v", errors)
 			}
@@ -126,3 +470,475 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+var formatCases []string = []string{
+	"=$A$1*B2",
+	"=SUM($A:A)+A$1:$B2",
+	"=A:A 2:2",
+	"=-1.5+$1:$3",
+	`=SUM("say ""hi""")`,
+	"=50.0%*A1<>A1&-B1%",
+	"=A1>=B1=C1<=C2",
+	"=TRUE<>FALSE&#N/A",
+	"=#NAME?+#REF!*#VALUE!-#NUM!/#CALC!",
+	"=1.5E-3+.5*2e+10-1:3",
+	"=IF(A1,,3)",
+	"=SUM(,B2)+1",
+	"=SUM(1,MAX(A1:B2,2),-3)",
+	"=(1+2)*3-(4-5)",
+	"=SUM()",
+	"=+A1+B1",
+	"=1++2",
+}
+
+func TestFormat(t *testing.T) {
+	for _, cell := range formatCases {
+		t.Run(cell, func(t *testing.T) {
+			n, errors, err := Parse(strings.NewReader(cell), testContext)
+			if err != nil || len(errors) != 0 {
+				t.Fatalf("Got parse errors: %v %v", err, errors)
+			}
+			if f := Format(n); f != cell {
+				t.Errorf("Expected %v, but got %v", cell, f)
+			}
+		})
+	}
+
+	// Missing operands are holes, in the place they are missing from
+	for _, cell := range []string{"=1+", "=*2", "=SUM(1*,2)", "=-"} {
+		n, errors, err := Parse(strings.NewReader(cell), testContext)
+		if err == nil || len(errors) != 1 {
+			t.Errorf("Expected a missing operand in %v, but got %v", cell, errors)
+		}
+		if f := Format(n); f != cell {
+			t.Errorf("Expected %v, but got %v", cell, f)
+		}
+	}
+
+	n := op(Multiplication, op(Subtraction, number("1"), op(Subtraction, number("2"), number("3"))), op(UnaryNegation, reference("A1")))
+	if f := Format(n); f != "=(1-(2-3))*-A1" {
+		t.Errorf("Expected parentheses where precedence requires them, but got %v", f)
+	}
+}
+
+func literal(v Value) *Node {
+	return &Node{kind: NodeKindLiteral, literalValue: v}
+}
+
+func number(text string) *Node {
+	n, _ := testContext.Numbers.ParseNumber(text)
+	return literal(NumberValue(n))
+}
+
+func reference(text string) *Node {
+	a, _ := ParseArea(text)
+	return &Node{
+		kind:         NodeKindReference,
+		rawValue:     text,
+		literalValue: RangeValue(testContext.Ranges.ParseRange(text)),
+		area:         a,
+	}
+}
+
+func op(o Operator, children ...*Node) *Node {
+	return &Node{kind: NodeKindOperator, operatorValue: o, children: children}
+}
+
+func function(name string, children ...*Node) *Node {
+	return &Node{kind: NodeKindFunction, rawValue: name, children: children}
+}
+
+func assertValueEqual(t *testing.T, expected Value, actual Value) {
+	if expected.IsA != actual.IsA {
+		t.Errorf("Expected value kind %v, but got %v", expected, actual)
+		return
+	}
+	switch expected.IsA {
+	case ValueKindNumber:
+		if expected.Number.String() != actual.Number.String() {
+			t.Errorf("Expected %v, but got %v", expected.Number, actual.Number)
+		}
+	case ValueKindError:
+		if !errors.Is(actual.Error, expected.Error) {
+			t.Errorf("Expected error %v, but got %v", expected.Error, actual.Error)
+		}
+	default:
+		if expected != actual {
+			t.Errorf("Expected %v, but got %v", expected, actual)
+		}
+	}
+}
+
+type evalTestCase struct {
+	name     string
+	formula  *Node
+	expected Value
+}
+
+var evalCases []evalTestCase = []evalTestCase{
+	evalTestCase{
+		name:     "addition",
+		formula:  op(Addition, number("1.5"), number("2")),
+		expected: number("3.5").literalValue,
+	},
+	evalTestCase{
+		name:     "text coerced to number",
+		formula:  op(Addition, literal(TextValue(" 2 ")), literal(LogicalValue(true))),
+		expected: number("3").literalValue,
+	},
+	evalTestCase{
+		name:     "text not a number",
+		formula:  op(Addition, number("1"), literal(TextValue("one"))),
+		expected: ErrorValue(ErrValue),
+	},
+	evalTestCase{
+		name:     "precedence tree",
+		formula:  op(Subtraction, number("10"), op(Multiplication, number("2"), op(Exponentiation, number("3"), number("2")))),
+		expected: number("-8").literalValue,
+	},
+	evalTestCase{
+		name:     "percent",
+		formula:  op(Percent, number("50")),
+		expected: number("0.5").literalValue,
+	},
+	evalTestCase{
+		name:     "negation",
+		formula:  op(UnaryNegation, number("4")),
+		expected: number("-4").literalValue,
+	},
+	evalTestCase{
+		name:     "division by zero",
+		formula:  op(Division, number("1"), number("0")),
+		expected: ErrorValue(ErrDiv0),
+	},
+	evalTestCase{
+		name:     "root of negative",
+		formula:  op(Exponentiation, number("-4"), number("0.5")),
+		expected: ErrorValue(ErrNum),
+	},
+	evalTestCase{
+		name:     "number comparison",
+		formula:  op(LessThanOrEqual, number("2"), number("10")),
+		expected: LogicalValue(true),
+	},
+	evalTestCase{
+		name:     "numbers sort before text",
+		formula:  op(LessThan, number("10"), literal(TextValue("1"))),
+		expected: LogicalValue(true),
+	},
+	evalTestCase{
+		name:     "concatenation",
+		formula:  op(Concatenation, literal(TextValue("total: ")), number("12")),
+		expected: TextValue("total: 12"),
+	},
+	evalTestCase{
+		name:     "text equality ignores case",
+		formula:  op(Equality, literal(TextValue("abc")), literal(TextValue("ABC"))),
+		expected: LogicalValue(true),
+	},
+	evalTestCase{
+		name:     "logicals sort after text",
+		formula:  op(GreaterThan, literal(LogicalValue(false)), literal(TextValue("zzz"))),
+		expected: LogicalValue(true),
+	},
+	evalTestCase{
+		name:     "sum",
+		formula:  function("sum", number("1"), number("2"), literal(TextValue("3"))),
+		expected: number("6").literalValue,
+	},
+	evalTestCase{
+		name:     "sum no arguments",
+		formula:  function("SUM"),
+		expected: number("0").literalValue,
+	},
+	evalTestCase{
+		name:     "reference",
+		formula:  op(Multiplication, reference("B2"), number("2")),
+		expected: number("44").literalValue,
+	},
+	evalTestCase{
+		name:     "empty cell",
+		formula:  op(Concatenation, reference("Z99"), literal(TextValue("x"))),
+		expected: TextValue("x"),
+	},
+	evalTestCase{
+		name:     "blank cell in arithmetic",
+		formula:  op(Addition, reference("Z99"), number("1")),
+		expected: number("1").literalValue,
+	},
+	evalTestCase{
+		name:     "blank cell equals zero",
+		formula:  op(Equality, reference("Z99"), number("0")),
+		expected: LogicalValue(true),
+	},
+	evalTestCase{
+		name:     "blank cell equals empty text",
+		formula:  op(Equality, literal(TextValue("")), reference("Z99")),
+		expected: LogicalValue(true),
+	},
+	evalTestCase{
+		name:     "blank cell equals FALSE",
+		formula:  op(Equality, reference("Z99"), literal(LogicalValue(false))),
+		expected: LogicalValue(true),
+	},
+	evalTestCase{
+		name:     "blank cells are equal",
+		formula:  op(Equality, reference("Z99"), reference("Y1")),
+		expected: LogicalValue(true),
+	},
+	evalTestCase{
+		name:     "empty text is not zero",
+		formula:  op(Equality, literal(TextValue("")), number("0")),
+		expected: LogicalValue(false),
+	},
+	evalTestCase{
+		name:     "count",
+		formula:  function("COUNT", reference("A1:D4"), literal(TextValue("1")), literal(TextValue("x"))),
+		expected: number("10").literalValue,
+	},
+	evalTestCase{
+		name:     "counta",
+		formula:  function("COUNTA", reference("A1:D4"), literal(TextValue(""))),
+		expected: number("11").literalValue,
+	},
+	evalTestCase{
+		name:     "max",
+		formula:  function("MAX", reference("A:D"), literal(TextValue("5"))),
+		expected: number("33").literalValue,
+	},
+	evalTestCase{
+		name:     "min",
+		formula:  function("MIN", reference("B1:D3"), literal(LogicalValue(true))),
+		expected: number("1").literalValue,
+	},
+	evalTestCase{
+		name:     "max of nothing",
+		formula:  function("MAX", reference("Y:Z")),
+		expected: number("0").literalValue,
+	},
+	evalTestCase{
+		name:     "max error",
+		formula:  function("MAX", reference("A1:B2"), literal(ErrorValue(ErrNA))),
+		expected: ErrorValue(ErrNA),
+	},
+	evalTestCase{
+		name:     "rows",
+		formula:  function("ROWS", reference("B2:D3")),
+		expected: number("2").literalValue,
+	},
+	evalTestCase{
+		name:     "columns of whole rows",
+		formula:  function("COLUMNS", reference("2:3")),
+		expected: number("16384").literalValue,
+	},
+	evalTestCase{
+		name:     "row",
+		formula:  function("ROW", reference("C3:D$2")),
+		expected: number("2").literalValue,
+	},
+	evalTestCase{
+		name:     "column of a number",
+		formula:  function("COLUMN", number("1")),
+		expected: ErrorValue(ErrValue),
+	},
+	evalTestCase{
+		name:     "index",
+		formula:  function("INDEX", reference("A1:C3"), number("2"), number("3")),
+		expected: number("32").literalValue,
+	},
+	evalTestCase{
+		name:     "index single row",
+		formula:  function("INDEX", reference("A2:C2"), number("2")),
+		expected: number("22").literalValue,
+	},
+	evalTestCase{
+		name:     "index single column",
+		formula:  function("INDEX", reference("B:B"), number("3")),
+		expected: number("23").literalValue,
+	},
+	evalTestCase{
+		name:     "index out of range",
+		formula:  function("INDEX", reference("A1:C3"), number("4"), number("1")),
+		expected: ErrorValue(ErrRef),
+	},
+	evalTestCase{
+		name:     "index of an empty intersection",
+		formula:  function("INDEX", op(Intersection, reference("A1"), reference("B2")), number("1")),
+		expected: ErrorValue(ErrNull),
+	},
+	evalTestCase{
+		name:     "sum area",
+		formula:  function("SUM", reference("A1:D2"), number("1")),
+		expected: number("130").literalValue,
+	},
+	evalTestCase{
+		name:     "sum whole column",
+		formula:  function("SUM", reference("$B:B")),
+		expected: number("66").literalValue,
+	},
+	evalTestCase{
+		name:     "sum whole rows",
+		formula:  function("SUM", reference("3:3")),
+		expected: number("69").literalValue,
+	},
+	evalTestCase{
+		name:     "intersection",
+		formula:  op(Addition, op(Intersection, reference("A1:C2"), reference("B:B")), number("0")),
+		expected: ErrorValue(ErrValue),
+	},
+	evalTestCase{
+		name:     "single cell intersection",
+		formula:  op(Addition, op(Intersection, reference("A2:C2"), reference("B:B")), number("0")),
+		expected: number("22").literalValue,
+	},
+	evalTestCase{
+		name:     "empty intersection",
+		formula:  function("SUM", op(Intersection, reference("A1"), reference("B2"))),
+		expected: ErrorValue(ErrNull),
+	},
+	evalTestCase{
+		name:     "not a reference",
+		formula:  op(Addition, reference("A0"), number("1")),
+		expected: ErrorValue(ErrRef),
+	},
+	evalTestCase{
+		name:     "unknown function",
+		formula:  function("nosuchfunction"),
+		expected: ErrorValue(ErrName),
+	},
+	evalTestCase{
+		name:     "leftmost error wins",
+		formula:  op(Addition, function("nosuchfunction"), literal(TextValue("one"))),
+		expected: ErrorValue(ErrName),
+	},
+	evalTestCase{
+		name:     "iserror",
+		formula:  function("ISERROR", op(Division, number("1"), number("0"))),
+		expected: LogicalValue(true),
+	},
+	evalTestCase{
+		name:     "iserr of #N/A",
+		formula:  function("ISERR", literal(ErrorValue(ErrNA))),
+		expected: LogicalValue(false),
+	},
+	evalTestCase{
+		name:     "isna of a number",
+		formula:  function("ISNA", reference("A1")),
+		expected: LogicalValue(false),
+	},
+	evalTestCase{
+		name:     "iferror",
+		formula:  function("IFERROR", op(Division, number("1"), number("0")), literal(TextValue("none"))),
+		expected: TextValue("none"),
+	},
+	evalTestCase{
+		name:     "iferror without error",
+		formula:  function("IFERROR", reference("A1"), literal(TextValue("none"))),
+		expected: number("11").literalValue,
+	},
+	evalTestCase{
+		name:     "ifna passes other errors",
+		formula:  function("IFNA", literal(ErrorValue(ErrRef)), number("0")),
+		expected: ErrorValue(ErrRef),
+	},
+	evalTestCase{
+		name:     "error.type",
+		formula:  function("ERROR.TYPE", op(Division, number("1"), number("0"))),
+		expected: number("2").literalValue,
+	},
+	evalTestCase{
+		name:     "error.type of a number",
+		formula:  function("ERROR.TYPE", number("1")),
+		expected: ErrorValue(ErrNA),
+	},
+	evalTestCase{
+		name:     "error for text that is not a number",
+		formula:  op(Addition, literal(TextValue("abc")), number("1")),
+		expected: ErrorValue(ErrValue),
+	},
+}
+
+type formulaTestCase struct {
+	cell     string
+	expected Value
+}
+
+var formulaCases []formulaTestCase = []formulaTestCase{
+	formulaTestCase{`=1.5&"x"`, TextValue("1.5x")},
+	formulaTestCase{"=2.0>=2.0", LogicalValue(true)},
+	formulaTestCase{"=1.0<=0.5", LogicalValue(false)},
+	formulaTestCase{"=1.0=1.0", LogicalValue(true)},
+	formulaTestCase{"=1.0<>1.0", LogicalValue(false)},
+	formulaTestCase{"=1.0>0.5", LogicalValue(true)},
+	formulaTestCase{"=1.0<0.5", LogicalValue(false)},
+	formulaTestCase{"=1.0+1.0=2.0", LogicalValue(true)},
+	formulaTestCase{`="a"&"b"="AB"`, LogicalValue(true)},
+	formulaTestCase{"=50.0%*A1", number("5.5").literalValue},
+	formulaTestCase{"=A1*50.0%", number("5.5").literalValue},
+	formulaTestCase{"=50.0%-1.0", number("-0.5").literalValue},
+	formulaTestCase{"=-50.0%", number("-0.5").literalValue},
+	formulaTestCase{"=4.0^50.0%", number("2").literalValue},
+	formulaTestCase{"=1.0+2.0*3.0^2.0", number("19").literalValue},
+	formulaTestCase{"=A1:C1 B:B*2.0", number("42").literalValue},
+	formulaTestCase{"=TRUE", LogicalValue(true)},
+	formulaTestCase{"=true=False", LogicalValue(false)},
+	formulaTestCase{"=TRUE+1.5", number("2.5").literalValue},
+	formulaTestCase{"=#DIV/0!", ErrorValue(ErrDiv0)},
+	formulaTestCase{"=1.5+#n/a", ErrorValue(ErrNA)},
+	formulaTestCase{"=#NULL!&#GETTING_DATA", ErrorValue(ErrNull)},
+	formulaTestCase{"=#SPILL!", ErrorValue(ErrSpill)},
+	formulaTestCase{"=2*3", number("6").literalValue},
+	formulaTestCase{"=.5+1", number("1.5").literalValue},
+	formulaTestCase{"=1.5E-3*1000", number("1.5").literalValue},
+	formulaTestCase{"=1e2+1E+1", number("110").literalValue},
+	formulaTestCase{"=1.+007", number("8").literalValue},
+	formulaTestCase{"=10%", number("0.1").literalValue},
+	formulaTestCase{"=2^3-1", number("7").literalValue},
+	formulaTestCase{"=ISNA(#N/A)", LogicalValue(true)},
+	formulaTestCase{"=error.type(#NULL!)", number("1").literalValue},
+	formulaTestCase{"=+A1+B1", number("32").literalValue},
+	formulaTestCase{"=1++2", number("3").literalValue},
+	formulaTestCase{`=+"a"`, TextValue("a")},
+	formulaTestCase{"=SUM(1,2)", number("3").literalValue},
+	formulaTestCase{"=SUM(1+2)*2", number("6").literalValue},
+	formulaTestCase{"=SUM(1,2*3,-4)", number("3").literalValue},
+	formulaTestCase{"=SUM(1,MAX(2,3),MIN(4,5))+1", number("9").literalValue},
+	formulaTestCase{"=1+SUM(,B2)", number("23").literalValue},
+	formulaTestCase{"=SUM((1+2)*3,(4))", number("13").literalValue},
+	formulaTestCase{"=(1+2)*3", number("9").literalValue},
+	formulaTestCase{"=(1)-2", number("-1").literalValue},
+	formulaTestCase{"=-(2-5)", number("3").literalValue},
+	formulaTestCase{"=IFERROR(1/0,2)", number("2").literalValue},
+	formulaTestCase{"=INDEX(A1:C3,2,3)", number("32").literalValue},
+	formulaTestCase{"=INDEX(A1:C3,ROWS(A1:B2),COLUMNS(A1:C1))", number("32").literalValue},
+}
+
+func TestEvalFormula(t *testing.T) {
+	for _, c := range formulaCases {
+		t.Run(c.cell, func(t *testing.T) {
+			n, errors, err := Parse(strings.NewReader(c.cell), testContext)
+			if err != nil || len(errors) != 0 {
+				t.Fatalf("Got parse errors: %v %v", err, errors)
+			}
+			assertValueEqual(t, c.expected, Eval(n, testContext))
+		})
+	}
+}
+
+func TestImplicitIntersection(t *testing.T) {
+	ctx := testContext
+	ctx.Cell = CellRef{Column: 5, Row: 2}
+	assertValueEqual(t, number("32").literalValue, Eval(op(Addition, reference("C1:C3"), number("0")), ctx))
+	assertValueEqual(t, ErrorValue(ErrValue), Eval(op(Addition, reference("A1:C1"), number("0")), ctx))
+	n, _, _ := Parse(strings.NewReader("=B1:B3*2"), ctx)
+	assertValueEqual(t, number("44").literalValue, Eval(n, ctx))
+	assertValueEqual(t, ErrorValue(ErrValue), Eval(n, testContext))
+}
+
+func TestEval(t *testing.T) {
+	for _, c := range evalCases {
+		t.Run(c.name, func(t *testing.T) {
+			assertValueEqual(t, c.expected, Eval(c.formula, testContext))
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package effe_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JoinCAD/effe"
+)
+
+// Find the cells a formula depends on
+func ExampleInspect() {
+	ctx := effe.Context{Numbers: effe.Float64NumberProvider{}, Ranges: effe.NewMemoryRangeProvider()}
+	n, _, _ := effe.Parse(strings.NewReader("=SUM(A1:B2)*$C$3-D4"), ctx)
+	effe.Inspect(n, func(n *effe.Node) bool {
+		if n.Kind() == effe.NodeKindReference {
+			from, to := n.Area().Rows()
+			fmt.Println(n.Text(), "rows", from, "to", to)
+		}
+		return true
+	})
+	// Output:
+	// A1:B2 rows 1 to 2
+	// $C$3 rows 3 to 3
+	// D4 rows 4 to 4
+}
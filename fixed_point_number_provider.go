@@ -0,0 +1,223 @@
+package effe
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// The largest supported FixedPointNumberProvider.Scale. Larger scales could not
+// hold 100, which % divides by.
+const MaxFixedPointScale = 16
+
+var fixedPointPow10 = func() (p [MaxFixedPointScale + 1]uint64) {
+	p[0] = 1
+	for i := 1; i < len(p); i++ {
+		p[i] = p[i-1] * 10
+	}
+	return p
+}()
+
+// FixedPointNumberProvider implements decimal arithmetic on int64 counts of
+// minor units, eg: cents when Scale is 2, so arithmetic never allocates. Results
+// that need more than Scale decimal places are rounded using Rounding, and
+// results that do not fit in an int64 are reported as #NUM!.
+//
+// Scale is capped at MaxFixedPointScale. FromInt saturates, rather than
+// overflowing, for integers outside of the representable range, and formulas
+// report #NUM! for such integers, eg: =ROWS(A:A) with a Scale of 16.
+type FixedPointNumberProvider struct {
+	Scale    uint
+	Rounding RoundingMode
+}
+
+type fixedPointNumber struct {
+	units int64
+	scale uint
+}
+
+func (n fixedPointNumber) String() string {
+	s := strconv.FormatInt(n.units, 10)
+	if n.scale == 0 {
+		return s
+	}
+	sign := ""
+	if n.units < 0 {
+		sign, s = "-", s[1:]
+	}
+	if pad := int(n.scale) - len(s) + 1; pad > 0 {
+		s = strings.Repeat("0", pad) + s
+	}
+	point := len(s) - int(n.scale)
+	whole, fraction := s[:point], strings.TrimRight(s[point:], "0")
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
+
+func (p FixedPointNumberProvider) scale() uint {
+	return min(p.Scale, MaxFixedPointScale)
+}
+
+func (p FixedPointNumberProvider) number(units int64) Number {
+	return fixedPointNumber{units: units, scale: p.scale()}
+}
+
+// ParseNumber accepts the same syntax as DecimalNumberProvider
+func (p FixedPointNumberProvider) ParseNumber(text string) (Number, error) {
+	n, err := DecimalNumberProvider{Precision: uint(len(text))}.ParseNumber(text)
+	if err != nil {
+		return nil, err
+	}
+	return p.fromDecimal(n.(decimalNumber))
+}
+
+// fromDecimal rounds d to Scale decimal places
+func (p FixedPointNumberProvider) fromDecimal(d decimalNumber) (Number, error) {
+	coef := d.coef
+	if shift := d.exp + int(p.scale()); shift > 0 {
+		coef = new(big.Int).Mul(coef, pow10(shift))
+	} else if shift < 0 {
+		q, r := new(big.Int).QuoRem(new(big.Int).Abs(coef), pow10(-shift), new(big.Int))
+		half := r.Mul(r, big.NewInt(2)).Cmp(pow10(-shift))
+		if (p.Rounding == RoundHalfUp && half >= 0) ||
+			(p.Rounding == RoundHalfEven && (half > 0 || (half == 0 && q.Bit(0) == 1))) {
+			q.Add(q, big.NewInt(1))
+		}
+		if coef.Sign() < 0 {
+			q.Neg(q)
+		}
+		coef = q
+	}
+	if !coef.IsInt64() {
+		return nil, ErrNum
+	}
+	return p.number(coef.Int64()), nil
+}
+
+func (n fixedPointNumber) decimal() decimalNumber {
+	return decimalNumber{coef: big.NewInt(n.units), exp: -int(n.scale)}
+}
+
+func (p FixedPointNumberProvider) FromInt(i int64) Number {
+	limit := int64(math.MaxInt64 / fixedPointPow10[p.scale()])
+	switch {
+	case i > limit:
+		return p.number(math.MaxInt64)
+	case i < -limit:
+		return p.number(-math.MaxInt64)
+	}
+	return p.number(i * int64(fixedPointPow10[p.scale()]))
+}
+
+func (p FixedPointNumberProvider) Add(a Number, b Number) (Number, error) {
+	au := a.(fixedPointNumber).units
+	bu := b.(fixedPointNumber).units
+	sum := au + bu
+	if (sum > au) != (bu > 0) {
+		return nil, ErrNum
+	}
+	return p.number(sum), nil
+}
+
+func (p FixedPointNumberProvider) Sub(a Number, b Number) (Number, error) {
+	au := a.(fixedPointNumber).units
+	bu := b.(fixedPointNumber).units
+	diff := au - bu
+	if (diff < au) != (bu > 0) {
+		return nil, ErrNum
+	}
+	return p.number(diff), nil
+}
+
+// Mul computes a * b / 10^scale, using a 128 bit intermediate product
+func (p FixedPointNumberProvider) Mul(a Number, b Number) (Number, error) {
+	au := a.(fixedPointNumber).units
+	bu := b.(fixedPointNumber).units
+	hi, lo := bits.Mul64(abs64(au), abs64(bu))
+	return p.quotient(hi, lo, fixedPointPow10[p.scale()], (au < 0) != (bu < 0))
+}
+
+// Div computes a * 10^scale / b, using a 128 bit intermediate dividend
+func (p FixedPointNumberProvider) Div(a Number, b Number) (Number, error) {
+	au := a.(fixedPointNumber).units
+	bu := b.(fixedPointNumber).units
+	if bu == 0 {
+		return nil, ErrDiv0
+	}
+	hi, lo := bits.Mul64(abs64(au), fixedPointPow10[p.scale()])
+	return p.quotient(hi, lo, abs64(bu), (au < 0) != (bu < 0))
+}
+
+// quotient divides the 128 bit magnitude hi:lo by d, rounding the result
+func (p FixedPointNumberProvider) quotient(hi uint64, lo uint64, d uint64, negative bool) (Number, error) {
+	if hi >= d {
+		return nil, ErrNum
+	}
+	q, r := bits.Div64(hi, lo, d)
+	// Compare r to d/2 without overflowing
+	if (p.Rounding == RoundHalfUp && r >= d-r) ||
+		(p.Rounding == RoundHalfEven && (r > d-r || (r == d-r && q&1 == 1))) {
+		q++
+	}
+	if q > math.MaxInt64 {
+		return nil, ErrNum
+	}
+	if negative {
+		return p.number(-int64(q)), nil
+	}
+	return p.number(int64(q)), nil
+}
+
+func abs64(i int64) uint64 {
+	if i < 0 {
+		return uint64(-i)
+	}
+	return uint64(i)
+}
+
+// fixedPointPowDigits covers the 19 digits of an int64 with room to spare, so
+// a power is only rounded once, to Scale, eg: =1.05^10 is 1.63 rather than the
+// 1.61 of rounding every product
+const fixedPointPowDigits = 40
+
+// Pow computes the power with DecimalNumberProvider, then rounds it to Scale
+func (p FixedPointNumberProvider) Pow(a Number, b Number) (Number, error) {
+	d := DecimalNumberProvider{Precision: fixedPointPowDigits, Rounding: p.Rounding}
+	n, err := d.Pow(a.(fixedPointNumber).decimal(), b.(fixedPointNumber).decimal())
+	if err != nil {
+		return nil, err
+	}
+	return p.fromDecimal(n.(decimalNumber))
+}
+
+func (p FixedPointNumberProvider) Neg(a Number) (Number, error) {
+	au := a.(fixedPointNumber).units
+	if au == math.MinInt64 {
+		return nil, ErrNum
+	}
+	return p.number(-au), nil
+}
+
+func (p FixedPointNumberProvider) Cmp(a Number, b Number) int {
+	au := a.(fixedPointNumber).units
+	bu := b.(fixedPointNumber).units
+	switch {
+	case au < bu:
+		return -1
+	case au > bu:
+		return 1
+	}
+	return 0
+}
+
+func (p FixedPointNumberProvider) ToFloat(a Number) float64 {
+	return float64(a.(fixedPointNumber).units) / float64(fixedPointPow10[p.scale()])
+}
+
+func (p FixedPointNumberProvider) ToInt(a Number) (int64, error) {
+	return a.(fixedPointNumber).units / int64(fixedPointPow10[p.scale()]), nil
+}
@@ -2,24 +2,187 @@ package effe
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 )
 
-type float64NumberProvider struct{}
+// Float64NumberProvider implements arithmetic with float64.
+//
+// Setting ExcelCompatible mimics excel: numbers keep full float64 precision, but
+// are compared and formatted to 15 significant digits, additions and
+// subtractions that cancel to almost zero are made exactly zero, literals such
+// as 1e999 are rejected with #NUM!, and numbers are formatted like excel's
+// General format, eg: 1000000 rather than 1e+06.
+type Float64NumberProvider struct {
+	ExcelCompatible bool
+}
+
+// excelSignificantDigits is the precision excel shows and compares numbers to
+const excelSignificantDigits = 15
+
+// excelCancellation is how close to zero, relative to its operands, the result
+// of an addition or subtraction has to be for excel to treat it as zero.
+const excelCancellation = 0x1p-50
+
+type float64Number struct {
+	f     float64
+	excel bool
+}
 
-type float64Number float64
+func (n float64Number) String() string {
+	if n.excel {
+		return excelText(n.f)
+	}
+	return fmt.Sprint(n.f)
+}
 
-func (f float64Number) String() string {
-	return fmt.Sprint(float64(f))
+// excelText formats f as excel does when converting a number to text: up to 15
+// significant digits, written out in full, eg: 123456789012, unless it is below
+// 1E-9 or from 1E+21 up, eg: 1.5E+21 or 1E-10
+func excelText(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	// The exponent after rounding, eg: 9.9999999999999999E+20 rounds up to 1E+21
+	mantissa, exp, _ := strings.Cut(strconv.FormatFloat(f, 'e', excelSignificantDigits-1, 64), "e")
+	e, _ := strconv.Atoi(exp)
+	if e >= -9 && e < 21 {
+		rounded, _ := strconv.ParseFloat(mantissa+"e"+exp, 64)
+		return strconv.FormatFloat(rounded, 'f', -1, 64)
+	}
+	mantissa = strings.TrimSuffix(strings.TrimRight(mantissa, "0"), ".")
+	sign := "+"
+	if e < 0 {
+		sign, e = "-", -e
+	}
+	return fmt.Sprintf("%sE%s%02d", mantissa, sign, e)
 }
 
-func (p float64NumberProvider) ParseNumber(text string) (Number, error) {
+// ParseNumber accepts the same syntax as DecimalNumberProvider. Go syntax that
+// excel does not accept, such as 1_000, 0x10 or Inf, is rejected.
+func (p Float64NumberProvider) ParseNumber(text string) (Number, error) {
+	if !isExcelNumber(text) {
+		return nil, fmt.Errorf("invalid number: %q", text)
+	}
 	f, err := strconv.ParseFloat(text, 64)
-	return float64Number(f), err
+	if p.ExcelCompatible {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, ErrNum
+		}
+		if err != nil {
+			return nil, err
+		}
+		return p.result(f)
+	}
+	return p.number(f), err
+}
+
+func (p Float64NumberProvider) FromInt(i int64) Number {
+	return p.number(float64(i))
+}
+
+func (p Float64NumberProvider) number(f float64) Number {
+	return float64Number{f: f, excel: p.ExcelCompatible}
+}
+
+func (p Float64NumberProvider) Add(a Number, b Number) (Number, error) {
+	af := a.(float64Number).f
+	bf := b.(float64Number).f
+	return p.result(p.cancel(af+bf, af, bf))
+}
+
+func (p Float64NumberProvider) Sub(a Number, b Number) (Number, error) {
+	af := a.(float64Number).f
+	bf := b.(float64Number).f
+	return p.result(p.cancel(af-bf, af, bf))
+}
+
+func (p Float64NumberProvider) Mul(a Number, b Number) (Number, error) {
+	af := a.(float64Number).f
+	bf := b.(float64Number).f
+	return p.result(af * bf)
+}
+
+func (p Float64NumberProvider) Div(a Number, b Number) (Number, error) {
+	af := a.(float64Number).f
+	bf := b.(float64Number).f
+	if bf == 0 {
+		return nil, ErrDiv0
+	}
+	return p.result(af / bf)
+}
+
+func (p Float64NumberProvider) Pow(a Number, b Number) (Number, error) {
+	af := a.(float64Number).f
+	bf := b.(float64Number).f
+	if af == 0 && bf == 0 {
+		// excel refuses to pick a value for 0^0
+		return nil, ErrNum
+	}
+	if af == 0 && bf < 0 {
+		return nil, ErrDiv0
+	}
+	return p.result(math.Pow(af, bf))
+}
+
+func (p Float64NumberProvider) Neg(a Number) (Number, error) {
+	return p.result(-a.(float64Number).f)
+}
+
+// Cmp compares to 15 significant digits when ExcelCompatible, eg: =0.1*3=0.3 is
+// TRUE, though 0.1*3 is 0.30000000000000004
+func (p Float64NumberProvider) Cmp(a Number, b Number) int {
+	af := a.(float64Number).f
+	bf := b.(float64Number).f
+	if p.ExcelCompatible {
+		af, bf = excelRound(af), excelRound(bf)
+	}
+	switch {
+	case af < bf:
+		return -1
+	case af > bf:
+		return 1
+	}
+	return 0
+}
+
+func (p Float64NumberProvider) ToFloat(a Number) float64 {
+	return a.(float64Number).f
+}
+
+func (p Float64NumberProvider) ToInt(a Number) (int64, error) {
+	af := math.Trunc(a.(float64Number).f)
+	if math.IsNaN(af) || af < math.MinInt64 || af >= math.MaxInt64 {
+		return 0, ErrNum
+	}
+	return int64(af), nil
+}
+
+// result rejects values excel cannot represent, eg: overflow, or the square
+// root of a negative number.
+func (p Float64NumberProvider) result(f float64) (Number, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, ErrNum
+	}
+	if f == 0 {
+		// excel has no negative zero
+		return p.number(0), nil
+	}
+	return p.number(f), nil
+}
+
+// excelRound rounds f to the digits excel shows
+func excelRound(f float64) float64 {
+	f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', excelSignificantDigits, 64), 64)
+	return f
 }
 
-func (p float64NumberProvider) Add(a Number, b Number) (Number, error) {
-	af := a.(float64Number)
-	bf := b.(float64Number)
-	return af + bf, nil
+// cancel snaps the sum or difference f of a and b to zero when it is only the
+// rounding error left over from operands that cancel, eg: =0.3-0.2-0.1
+func (p Float64NumberProvider) cancel(f float64, a float64, b float64) float64 {
+	if p.ExcelCompatible && math.Abs(f) <= math.Max(math.Abs(a), math.Abs(b))*excelCancellation {
+		return 0
+	}
+	return f
 }
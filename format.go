@@ -0,0 +1,91 @@
+package effe
+
+import (
+	"strings"
+)
+
+// Format renders a parsed formula as formula text, eg: "=$A$1*B2". Literals are
+// written as they were parsed, and parentheses are only written where operator
+// precedence requires them.
+func Format(n *Node) string {
+	var sb strings.Builder
+	sb.WriteByte('=')
+	format(&sb, n)
+	return sb.String()
+}
+
+func format(sb *strings.Builder, n *Node) {
+	switch n.kind {
+	case NodeKindLiteral:
+		formatLiteral(sb, n)
+	case NodeKindReference:
+		if n.rawValue != "" {
+			sb.WriteString(n.rawValue)
+		} else {
+			sb.WriteString(n.area.String())
+		}
+	case NodeKindFunction:
+		sb.WriteString(n.rawValue)
+		sb.WriteByte('(')
+		for i, c := range n.children {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			format(sb, c)
+		}
+		sb.WriteByte(')')
+	case NodeKindOperator:
+		formatOperator(sb, n)
+	}
+}
+
+func formatLiteral(sb *strings.Builder, n *Node) {
+	if n.rawValue != "" {
+		sb.WriteString(n.rawValue)
+		return
+	}
+	v := n.literalValue
+	switch v.IsA {
+	case ValueKindText:
+		sb.WriteByte('"')
+		sb.WriteString(strings.ReplaceAll(v.Text, `"`, `""`))
+		sb.WriteByte('"')
+	case ValueKindError:
+		sb.WriteString(AsExcelError(v.Error).String())
+	case ValueKindNumber:
+		sb.WriteString(v.Number.String())
+	case ValueKindLogical:
+		sb.WriteString(logicalText(v.Logical))
+	}
+}
+
+func formatOperator(sb *strings.Builder, n *Node) {
+	o := n.operatorValue
+	switch operatorArgs(o) {
+	case 1:
+		if prefix(o) {
+			sb.WriteString(operatorSymbol(o))
+			formatOperand(sb, n.children[0], operatorPrecedence(o))
+		} else {
+			formatOperand(sb, n.children[0], operatorPrecedence(o))
+			sb.WriteString(operatorSymbol(o))
+		}
+	default:
+		// Left associative operators need parentheses around an equally binding
+		// right operand, eg: =1-(2-3)
+		formatOperand(sb, n.children[0], operatorPrecedence(o))
+		sb.WriteString(operatorSymbol(o))
+		formatOperand(sb, n.children[1], operatorPrecedence(o)+1)
+	}
+}
+
+// formatOperand writes n, in parentheses if it binds less tightly than precedence
+func formatOperand(sb *strings.Builder, n *Node, precedence int) {
+	if n.kind != NodeKindOperator || operatorPrecedence(n.operatorValue) >= precedence {
+		format(sb, n)
+		return
+	}
+	sb.WriteByte('(')
+	format(sb, n)
+	sb.WriteByte(')')
+}
@@ -1 +1,330 @@
 package effe
+
+// formula implements a spreadsheet function over its evaluated arguments
+type formula func(ctx Context, args []Value) Value
+
+// formulas is keyed by upper case function name
+var formulas = map[string]formula{
+	"SUM":        sum,
+	"COUNT":      count,
+	"COUNTA":     countA,
+	"MAX":        maxOf,
+	"MIN":        minOf,
+	"ROWS":       rowsOf,
+	"COLUMNS":    columnsOf,
+	"ROW":        rowOf,
+	"COLUMN":     columnOf,
+	"INDEX":      index,
+	"ISERROR":    isError,
+	"ISERR":      isErr,
+	"ISNA":       isNA,
+	"IFERROR":    ifError,
+	"IFNA":       ifNA,
+	"ERROR.TYPE": errorType,
+}
+
+// eachNumber calls f with the numbers an aggregate works on. Numbers inside
+// references are included, while text and logicals inside references are
+// ignored; arguments given directly are coerced. The first error stops it.
+func eachNumber(ctx Context, args []Value, f func(n Number) error) error {
+	for _, a := range args {
+		if a.IsA == ValueKindRange {
+			for _, v := range populatedValues(ctx.Ranges, a.Range) {
+				if v.IsA == ValueKindError {
+					return v.Error
+				}
+				if v.IsA != ValueKindNumber {
+					continue
+				}
+				if err := f(v.Number); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		n, err := a.ToNumber(ctx)
+		if err != nil {
+			return err
+		}
+		if err := f(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sum adds its arguments
+func sum(ctx Context, args []Value) Value {
+	total := ctx.Numbers.FromInt(0)
+	err := eachNumber(ctx, args, func(n Number) (err error) {
+		total, err = ctx.Numbers.Add(total, n)
+		return err
+	})
+	if err != nil {
+		return ErrorValue(err)
+	}
+	return NumberValue(total)
+}
+
+// maxOf returns the largest of its arguments, or 0 if there are none
+func maxOf(ctx Context, args []Value) Value {
+	return extreme(ctx, args, 1)
+}
+
+// minOf returns the smallest of its arguments, or 0 if there are none
+func minOf(ctx Context, args []Value) Value {
+	return extreme(ctx, args, -1)
+}
+
+// extreme finds the largest number when sign is 1, and the smallest when -1
+func extreme(ctx Context, args []Value, sign int) Value {
+	var result Number
+	err := eachNumber(ctx, args, func(n Number) error {
+		if result == nil || ctx.Numbers.Cmp(n, result)*sign > 0 {
+			result = n
+		}
+		return nil
+	})
+	if err != nil {
+		return ErrorValue(err)
+	}
+	if result == nil {
+		return NumberValue(ctx.Numbers.FromInt(0))
+	}
+	return NumberValue(result)
+}
+
+// intValue returns i as a number, or #NUM! if ctx.Numbers cannot represent it
+func intValue(ctx Context, i int64) Value {
+	n, err := fromInt(ctx.Numbers, i)
+	if err != nil {
+		return ErrorValue(err)
+	}
+	return NumberValue(n)
+}
+
+// count counts numbers. Numbers inside references are counted, while arguments
+// given directly are counted if they can be coerced to a number.
+func count(ctx Context, args []Value) Value {
+	var n int64
+	for _, a := range args {
+		if a.IsA == ValueKindRange {
+			for _, v := range populatedValues(ctx.Ranges, a.Range) {
+				if v.IsA == ValueKindNumber {
+					n++
+				}
+			}
+			continue
+		}
+		if a.IsA == ValueKindBlank {
+			continue
+		}
+		if _, err := a.ToNumber(ctx); err == nil {
+			n++
+		}
+	}
+	return intValue(ctx, n)
+}
+
+// countA counts values that are not blank, including errors and empty text
+func countA(ctx Context, args []Value) Value {
+	var n int64
+	for _, a := range args {
+		if a.IsA == ValueKindRange {
+			for _, v := range populatedValues(ctx.Ranges, a.Range) {
+				if v.IsA != ValueKindBlank {
+					n++
+				}
+			}
+			continue
+		}
+		if a.IsA != ValueKindBlank {
+			n++
+		}
+	}
+	return intValue(ctx, n)
+}
+
+// errorArg resolves a single argument, returning the excel error it holds, if any
+func errorArg(ctx Context, args []Value) (Value, *ExcelError) {
+	v := args[0].ToScalar(ctx)
+	if v.IsA != ValueKindError {
+		return v, nil
+	}
+	return v, AsExcelError(v.Error)
+}
+
+// isError is TRUE for any error
+func isError(ctx Context, args []Value) Value {
+	if len(args) != 1 {
+		return ErrorValue(ErrValue)
+	}
+	_, e := errorArg(ctx, args)
+	return LogicalValue(e != nil)
+}
+
+// isErr is TRUE for any error except #N/A
+func isErr(ctx Context, args []Value) Value {
+	if len(args) != 1 {
+		return ErrorValue(ErrValue)
+	}
+	_, e := errorArg(ctx, args)
+	return LogicalValue(e != nil && e.Code != ErrorCodeNA)
+}
+
+// isNA is TRUE only for #N/A
+func isNA(ctx Context, args []Value) Value {
+	if len(args) != 1 {
+		return ErrorValue(ErrValue)
+	}
+	_, e := errorArg(ctx, args)
+	return LogicalValue(e != nil && e.Code == ErrorCodeNA)
+}
+
+// ifError returns its second argument in place of any error in its first
+func ifError(ctx Context, args []Value) Value {
+	if len(args) != 2 {
+		return ErrorValue(ErrValue)
+	}
+	v, e := errorArg(ctx, args)
+	if e != nil {
+		return args[1].ToScalar(ctx)
+	}
+	return v
+}
+
+// ifNA returns its second argument in place of #N/A in its first
+func ifNA(ctx Context, args []Value) Value {
+	if len(args) != 2 {
+		return ErrorValue(ErrValue)
+	}
+	v, e := errorArg(ctx, args)
+	if e != nil && e.Code == ErrorCodeNA {
+		return args[1].ToScalar(ctx)
+	}
+	return v
+}
+
+// errorType returns the ErrorCode of an error, eg: 2 for #DIV/0!, and #N/A for
+// anything that is not an error
+func errorType(ctx Context, args []Value) Value {
+	if len(args) != 1 {
+		return ErrorValue(ErrValue)
+	}
+	_, e := errorArg(ctx, args)
+	if e == nil {
+		return ErrorValue(ErrNA)
+	}
+	return intValue(ctx, int64(e.Code))
+}
+
+// rangeArg resolves a reference argument that knows its shape
+func rangeArg(a Value) (RangeGeometry, error) {
+	if a.IsA == ValueKindError {
+		return nil, a.Error
+	}
+	if a.IsA != ValueKindRange {
+		return nil, ErrValue
+	}
+	g, ok := a.Range.(RangeGeometry)
+	if !ok {
+		return nil, ErrValue
+	}
+	if rows, _ := g.Dimensions(); rows == 0 {
+		if v := g.At(1, 1); v.IsA == ValueKindError {
+			return nil, v.Error
+		}
+		return nil, ErrRef
+	}
+	return g, nil
+}
+
+// rowsOf returns the number of rows in a reference
+func rowsOf(ctx Context, args []Value) Value {
+	if len(args) != 1 {
+		return ErrorValue(ErrValue)
+	}
+	g, err := rangeArg(args[0])
+	if err != nil {
+		return ErrorValue(err)
+	}
+	rows, _ := g.Dimensions()
+	return intValue(ctx, int64(rows))
+}
+
+// columnsOf returns the number of columns in a reference
+func columnsOf(ctx Context, args []Value) Value {
+	if len(args) != 1 {
+		return ErrorValue(ErrValue)
+	}
+	g, err := rangeArg(args[0])
+	if err != nil {
+		return ErrorValue(err)
+	}
+	_, columns := g.Dimensions()
+	return intValue(ctx, int64(columns))
+}
+
+// rowOf returns the first row number of a reference, eg: 2 for B2:C3
+func rowOf(ctx Context, args []Value) Value {
+	if len(args) != 1 {
+		return ErrorValue(ErrValue)
+	}
+	g, err := rangeArg(args[0])
+	if err != nil {
+		return ErrorValue(err)
+	}
+	return intValue(ctx, int64(g.Origin().Row))
+}
+
+// columnOf returns the first column number of a reference, eg: 2 for B2:C3
+func columnOf(ctx Context, args []Value) Value {
+	if len(args) != 1 {
+		return ErrorValue(ErrValue)
+	}
+	g, err := rangeArg(args[0])
+	if err != nil {
+		return ErrorValue(err)
+	}
+	return intValue(ctx, int64(g.Origin().Column))
+}
+
+// index returns the cell of a reference at a row and column, counting from 1.
+// The column may be left out for a single column, and the row for a single
+// row, eg: =INDEX(A1:C1,2) is B1. Row or column 0, which excel uses to select
+// a whole column or row, is not supported.
+func index(ctx Context, args []Value) Value {
+	if len(args) < 2 || len(args) > 3 {
+		return ErrorValue(ErrValue)
+	}
+	g, err := rangeArg(args[0])
+	if err != nil {
+		return ErrorValue(err)
+	}
+	position := make([]int64, len(args)-1)
+	for i, a := range args[1:] {
+		n, err := a.ToNumber(ctx)
+		if err != nil {
+			return ErrorValue(err)
+		}
+		if position[i], err = ctx.Numbers.ToInt(n); err != nil {
+			return ErrorValue(err)
+		}
+	}
+
+	rows, columns := g.Dimensions()
+	row, column := position[0], int64(1)
+	if len(position) == 2 {
+		column = position[1]
+	} else if rows == 1 {
+		row, column = 1, position[0]
+	}
+	if row < 1 || column < 1 {
+		return ErrorValue(ErrValue)
+	}
+	if row > int64(rows) || column > int64(columns) {
+		return ErrorValue(ErrRef)
+	}
+	return g.At(int(row), int(column))
+}
@@ -0,0 +1,3 @@
+module github.com/JoinCAD/effe
+
+go 1.23
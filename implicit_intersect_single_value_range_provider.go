@@ -1,5 +1,7 @@
 package effe
 
+import "iter"
+
 type implicitIntersector struct {
 	rp     RangeProvider
 	target Range
@@ -21,9 +23,13 @@ func (i implicitIntersector) Single(a Range) Value {
 	if a.IsSingleValue() {
 		return i.rp.Single(a)
 	}
-	return i.rp.Single(i.rp.ImplicitIntersect(target, a))
+	return i.rp.Single(i.rp.ImplicitIntersect(i.target, a))
 }
 
-func (i implicitIntersector) Values(a Range) <-chan Value {
+func (i implicitIntersector) Values(a Range) iter.Seq2[CellRef, Value] {
 	return i.rp.Values(a)
 }
+
+func (i implicitIntersector) PopulatedValues(a Range) iter.Seq2[CellRef, Value] {
+	return populatedValues(i.rp, a)
+}
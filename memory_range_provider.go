@@ -0,0 +1,244 @@
+package effe
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"slices"
+)
+
+// memoryRange covers the columns clow to chi and rows rlow to rhi inclusive,
+// counting from 1, of the cells in m. A range that cannot be resolved carries
+// an error instead.
+type memoryRange struct {
+	m         *MemoryRangeProvider
+	clow, chi int
+	rlow, rhi int
+	err       error
+}
+
+func (mr memoryRange) IsSingleValue() bool {
+	return mr.err == nil && mr.clow == mr.chi && mr.rlow == mr.rhi
+}
+
+func (mr memoryRange) Dimensions() (int, int) {
+	if mr.err != nil {
+		return 0, 0
+	}
+	return mr.rhi - mr.rlow + 1, mr.chi - mr.clow + 1
+}
+
+func (mr memoryRange) Origin() CellRef {
+	return CellRef{Column: mr.clow, Row: mr.rlow}
+}
+
+func (mr memoryRange) At(row int, column int) Value {
+	if mr.err != nil {
+		return ErrorValue(mr.err)
+	}
+	rows, columns := mr.Dimensions()
+	if row < 1 || row > rows || column < 1 || column > columns {
+		return ErrorValue(ErrRef)
+	}
+	return mr.m.get(mr.clow+column-1, mr.rlow+row-1)
+}
+
+type cell struct {
+	column, row int
+}
+
+// MemoryRangeProvider is a RangeProvider over a sparse grid of cells held in
+// memory, for applications and tests that evaluate formulas against data they
+// already have. Cells that have not been set are blank.
+//
+// A MemoryRangeProvider must not be modified while formulas are evaluated.
+type MemoryRangeProvider struct {
+	cells             map[cell]Value
+	maxColumn, maxRow int
+}
+
+func NewMemoryRangeProvider() *MemoryRangeProvider {
+	return &MemoryRangeProvider{
+		cells: map[cell]Value{},
+	}
+}
+
+// Set stores v in a single cell, eg: "B2"
+func (m *MemoryRangeProvider) Set(ref string, v Value) error {
+	r, err := parseMemoryRange(ref)
+	if err != nil {
+		return err
+	}
+	if !r.IsSingleValue() {
+		return fmt.Errorf("not a single cell: %q", ref)
+	}
+	m.cells[cell{r.clow, r.rlow}] = v
+	m.maxColumn = max(m.maxColumn, r.clow)
+	m.maxRow = max(m.maxRow, r.rlow)
+	return nil
+}
+
+// ParseRange accepts any reference understood by ParseArea. Text that is not a
+// reference results in a range that evaluates to #REF!.
+func (m *MemoryRangeProvider) ParseRange(text string) Range {
+	r, err := parseMemoryRange(text)
+	if err != nil {
+		return memoryRange{err: ErrRef}
+	}
+	r.m = m
+	return r
+}
+
+func parseMemoryRange(text string) (memoryRange, error) {
+	a, err := ParseArea(text)
+	if err != nil {
+		return memoryRange{}, err
+	}
+	var mr memoryRange
+	mr.clow, mr.chi = a.Columns()
+	mr.rlow, mr.rhi = a.Rows()
+	return mr, nil
+}
+
+// Intersect returns the cells in both a and b, or #NULL! if there are none
+func (m *MemoryRangeProvider) Intersect(a Range, b Range) Range {
+	ar, br := a.(memoryRange), b.(memoryRange)
+	if ar.err != nil {
+		return ar
+	}
+	if br.err != nil {
+		return br
+	}
+	r := memoryRange{
+		m:    m,
+		clow: max(ar.clow, br.clow),
+		chi:  min(ar.chi, br.chi),
+		rlow: max(ar.rlow, br.rlow),
+		rhi:  min(ar.rhi, br.rhi),
+	}
+	if r.clow > r.chi || r.rlow > r.rhi {
+		return memoryRange{err: ErrNull}
+	}
+	return r
+}
+
+// ImplicitIntersect picks the cell of b in the same row or column as the single
+// cell a, eg: the cell of A1:A10 in the same row as the formula in C3 is A3. If
+// there is no such cell, b is returned unchanged.
+func (m *MemoryRangeProvider) ImplicitIntersect(a Range, b Range) Range {
+	target, br := a.(memoryRange), b.(memoryRange)
+	if br.err != nil || br.IsSingleValue() || !target.IsSingleValue() {
+		return br
+	}
+	if br.clow == br.chi && target.rlow >= br.rlow && target.rlow <= br.rhi {
+		return memoryRange{m: m, clow: br.clow, chi: br.clow, rlow: target.rlow, rhi: target.rlow}
+	}
+	if br.rlow == br.rhi && target.clow >= br.clow && target.clow <= br.chi {
+		return memoryRange{m: m, clow: target.clow, chi: target.clow, rlow: br.rlow, rhi: br.rlow}
+	}
+	return br
+}
+
+// Single returns the value of a single cell, or #VALUE! for a larger range
+func (m *MemoryRangeProvider) Single(a Range) Value {
+	r := a.(memoryRange)
+	if r.err != nil {
+		return ErrorValue(r.err)
+	}
+	if !r.IsSingleValue() {
+		return ErrorValue(ErrValue)
+	}
+	return m.get(r.clow, r.rlow)
+}
+
+func (m *MemoryRangeProvider) get(column int, row int) Value {
+	if v, ok := m.cells[cell{column, row}]; ok {
+		return v
+	}
+	return BlankValue()
+}
+
+// Values yields the cells of a row by row, stopping at the last row and column
+// that have been set
+func (m *MemoryRangeProvider) Values(a Range) iter.Seq2[CellRef, Value] {
+	r := a.(memoryRange)
+	return func(yield func(CellRef, Value) bool) {
+		if r.err != nil {
+			yield(CellRef{}, ErrorValue(r.err))
+			return
+		}
+		// Nothing has been set outside of the used area
+		for row := r.rlow; row <= min(r.rhi, m.maxRow); row++ {
+			for column := r.clow; column <= min(r.chi, m.maxColumn); column++ {
+				if !yield(CellRef{Column: column, Row: row}, m.get(column, row)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// PopulatedValues yields the cells of a that are not blank, row by row
+func (m *MemoryRangeProvider) PopulatedValues(a Range) iter.Seq2[CellRef, Value] {
+	r := a.(memoryRange)
+	return func(yield func(CellRef, Value) bool) {
+		if r.err != nil {
+			yield(CellRef{}, ErrorValue(r.err))
+			return
+		}
+		for _, c := range m.populated(r) {
+			if !yield(CellRef{Column: c.column, Row: c.row}, m.cells[c]) {
+				return
+			}
+		}
+	}
+}
+
+// UsedArea returns the smallest area covering the cells of a that are not blank
+func (m *MemoryRangeProvider) UsedArea(a Range) (Area, bool) {
+	r := a.(memoryRange)
+	if r.err != nil {
+		return Area{}, false
+	}
+	cells := m.populated(r)
+	if len(cells) == 0 {
+		return Area{}, false
+	}
+	used := memoryRange{clow: cells[0].column, chi: cells[0].column, rlow: cells[0].row, rhi: cells[len(cells)-1].row}
+	for _, c := range cells {
+		used.clow = min(used.clow, c.column)
+		used.chi = max(used.chi, c.column)
+	}
+	return Area{
+		From: CellRef{Column: used.clow, Row: used.rlow},
+		To:   CellRef{Column: used.chi, Row: used.rhi},
+	}, true
+}
+
+// populated returns the cells of r that are not blank, row by row. It walks
+// whichever is smaller of r and the cells that have been set.
+func (m *MemoryRangeProvider) populated(r memoryRange) []cell {
+	var cells []cell
+	columns := max(0, min(r.chi, m.maxColumn)-r.clow+1)
+	rows := max(0, min(r.rhi, m.maxRow)-r.rlow+1)
+	if columns*rows <= len(m.cells) {
+		for row := r.rlow; row < r.rlow+rows; row++ {
+			for column := r.clow; column < r.clow+columns; column++ {
+				if v, ok := m.cells[cell{column, row}]; ok && v.IsA != ValueKindBlank {
+					cells = append(cells, cell{column, row})
+				}
+			}
+		}
+		return cells
+	}
+
+	for c, v := range m.cells {
+		if v.IsA != ValueKindBlank && c.column >= r.clow && c.column <= r.chi && c.row >= r.rlow && c.row <= r.rhi {
+			cells = append(cells, c)
+		}
+	}
+	slices.SortFunc(cells, func(a, b cell) int {
+		return cmp.Or(cmp.Compare(a.row, b.row), cmp.Compare(a.column, b.column))
+	})
+	return cells
+}
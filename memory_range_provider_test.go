@@ -0,0 +1,83 @@
+package effe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMemoryRangeProviderValues(t *testing.T) {
+	m := testRanges()
+	var refs []string
+	for ref, v := range m.Values(m.ParseRange("C2:D3")) {
+		s, _ := v.ToText(testContext)
+		refs = append(refs, ref.String()+"="+s)
+	}
+	expected := []string{"C2=32", "D2=", "C3=33", "D3="}
+	if len(refs) != len(expected) {
+		t.Fatalf("Expected %v, but got %v", expected, refs)
+	}
+	for i := range expected {
+		if refs[i] != expected[i] {
+			t.Errorf("Expected %v, but got %v", expected, refs)
+		}
+	}
+
+	count := 0
+	for range m.Values(m.ParseRange("A:C")) {
+		count++
+		if count == 2 {
+			break
+		}
+	}
+	if count != 2 {
+		t.Errorf("Expected iteration to stop after 2 cells, but got %v", count)
+	}
+
+	for ref, v := range m.Values(m.ParseRange("A0")) {
+		if ref != (CellRef{}) || v.IsA != ValueKindError {
+			t.Errorf("Expected an error, but got %v %v", ref, v)
+		}
+	}
+}
+
+func TestMemoryRangeProviderSparse(t *testing.T) {
+	m := NewMemoryRangeProvider()
+	m.Set("B2", TextValue("x"))
+	m.Set("C1048576", NumberValue(Float64NumberProvider{}.FromInt(1)))
+	m.Set("A1048576", NumberValue(Float64NumberProvider{}.FromInt(2)))
+	m.Set("B3", BlankValue())
+
+	var refs []string
+	for ref := range m.PopulatedValues(m.ParseRange("A:C")) {
+		refs = append(refs, ref.String())
+	}
+	if strings.Join(refs, " ") != "B2 A1048576 C1048576" {
+		t.Errorf("Expected B2 A1048576 C1048576, but got %v", refs)
+	}
+
+	if a, ok := m.UsedArea(m.ParseRange("B:C")); !ok || a.String() != "B2:C1048576" {
+		t.Errorf("Expected B2:C1048576, but got %v %v", a, ok)
+	}
+	if a, ok := m.UsedArea(m.ParseRange("B3:D4")); ok {
+		t.Errorf("Expected no used area, but got %v", a)
+	}
+
+	ctx := Context{Numbers: Float64NumberProvider{}, Ranges: m}
+	assertValueEqual(t, NumberValue(Float64NumberProvider{}.FromInt(3)), Eval(function("SUM", reference("A:C")), ctx))
+}
+
+func TestMemoryRangeGeometry(t *testing.T) {
+	m := testRanges()
+	g := m.ParseRange("$B2:D3").(RangeGeometry)
+	if rows, columns := g.Dimensions(); rows != 2 || columns != 3 {
+		t.Errorf("Expected 2 rows and 3 columns, but got %v and %v", rows, columns)
+	}
+	if o := g.Origin(); o.String() != "B2" {
+		t.Errorf("Expected B2, but got %v", o)
+	}
+	assertValueEqual(t, number("33").literalValue, g.At(2, 2))
+	assertValueEqual(t, TextValue("ignored"), m.ParseRange("D1").(RangeGeometry).At(1, 1))
+	assertValueEqual(t, BlankValue(), g.At(1, 3))
+	assertValueEqual(t, ErrorValue(ErrRef), g.At(3, 1))
+	assertValueEqual(t, ErrorValue(ErrRef), m.ParseRange("A0").(RangeGeometry).At(1, 1))
+}
@@ -0,0 +1,343 @@
+package effe
+
+import (
+	"strings"
+	"testing"
+)
+
+type numberTestCase struct {
+	name     string
+	numbers  NumberProvider
+	cell     string
+	expected string
+}
+
+var numberCases []numberTestCase = []numberTestCase{
+	numberTestCase{
+		name:     "float64 is inexact",
+		numbers:  Float64NumberProvider{},
+		cell:     "=0.1+0.2",
+		expected: "0.30000000000000004",
+	},
+	numberTestCase{
+		name:     "excel float64 rounds to 15 digits",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=0.1+0.2",
+		expected: "0.3",
+	},
+	numberTestCase{
+		name:     "excel float64 literal rounds to 15 digits",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=1.23456789012345678+0.0",
+		expected: "1.23456789012346",
+	},
+	numberTestCase{
+		name:     "excel float64 cancellation",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=0.1+0.2-0.3",
+		expected: "0",
+	},
+	numberTestCase{
+		name:     "excel float64 keeps full precision",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=1/3*3",
+		expected: "1",
+	},
+	numberTestCase{
+		name:     "excel float64 compares to 15 digits",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=0.1*3=0.3",
+		expected: "TRUE",
+	},
+	numberTestCase{
+		name:     "excel float64 overflow",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=100000000.0^40.0",
+		expected: "#NUM!",
+	},
+	numberTestCase{
+		name:     "excel float64 writes out millions",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=1000000",
+		expected: "1000000",
+	},
+	numberTestCase{
+		name:     "excel float64 writes out 15 digits",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=123456789012345",
+		expected: "123456789012345",
+	},
+	numberTestCase{
+		name:     "excel float64 numbers as text",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     `=123456789012&""`,
+		expected: "123456789012",
+	},
+	numberTestCase{
+		name:     "excel float64 huge numbers are scientific",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=1E21",
+		expected: "1E+21",
+	},
+	numberTestCase{
+		name:     "excel float64 small fractions",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=0.0000001",
+		expected: "0.0000001",
+	},
+	numberTestCase{
+		name:     "excel float64 tiny numbers are scientific",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=-1.5E-10",
+		expected: "-1.5E-10",
+	},
+	numberTestCase{
+		name:     "excel float64 thirds",
+		numbers:  Float64NumberProvider{ExcelCompatible: true},
+		cell:     "=1/3",
+		expected: "0.333333333333333",
+	},
+	numberTestCase{
+		name:     "decimal is exact",
+		numbers:  DecimalNumberProvider{},
+		cell:     "=0.1+0.2",
+		expected: "0.3",
+	},
+	numberTestCase{
+		name:     "decimal literals are exact",
+		numbers:  DecimalNumberProvider{},
+		cell:     "=0.1E1+.2+3E-20",
+		expected: "1.20000000000000000003",
+	},
+	numberTestCase{
+		name:     "decimal division rounds half even",
+		numbers:  DecimalNumberProvider{Precision: 4},
+		cell:     "=2.0/3.0",
+		expected: "0.6667",
+	},
+	numberTestCase{
+		name:     "decimal half even tie",
+		numbers:  DecimalNumberProvider{Precision: 2},
+		cell:     "=1.25*1.0",
+		expected: "1.2",
+	},
+	numberTestCase{
+		name:     "decimal half up tie",
+		numbers:  DecimalNumberProvider{Precision: 2, Rounding: RoundHalfUp},
+		cell:     "=1.25*1.0",
+		expected: "1.3",
+	},
+	numberTestCase{
+		name:     "decimal round down",
+		numbers:  DecimalNumberProvider{Precision: 4, Rounding: RoundDown},
+		cell:     "=2.0/3.0",
+		expected: "0.6666",
+	},
+	numberTestCase{
+		name:     "decimal power",
+		numbers:  DecimalNumberProvider{},
+		cell:     "=1.1^2.0",
+		expected: "1.21",
+	},
+	numberTestCase{
+		name:     "decimal negative power overflow",
+		numbers:  DecimalNumberProvider{},
+		cell:     "=0.1^-99999999",
+		expected: "#NUM!",
+	},
+	numberTestCase{
+		name:     "decimal negative power underflow",
+		numbers:  DecimalNumberProvider{},
+		cell:     "=10^-99999999",
+		expected: "0",
+	},
+	numberTestCase{
+		name:     "decimal fractional power",
+		numbers:  DecimalNumberProvider{},
+		cell:     "=2^0.5",
+		expected: "1.414213562373095048801688724209698",
+	},
+	numberTestCase{
+		name:     "decimal tiny fractional power",
+		numbers:  DecimalNumberProvider{},
+		cell:     "=1.5^0.000000000000000000001",
+		expected: "1.000000000000000000000405465108108",
+	},
+	numberTestCase{
+		name:     "decimal division by zero",
+		numbers:  DecimalNumberProvider{},
+		cell:     "=1.0/0.0",
+		expected: "#DIV/0!",
+	},
+	numberTestCase{
+		name:     "rational is exact",
+		numbers:  RationalNumberProvider{},
+		cell:     "=1.0/3.0*3.0",
+		expected: "1",
+	},
+	numberTestCase{
+		name:     "rational terminating decimal",
+		numbers:  RationalNumberProvider{},
+		cell:     "=1.0/8.0",
+		expected: "0.125",
+	},
+	numberTestCase{
+		name:     "rational repeating decimal",
+		numbers:  RationalNumberProvider{},
+		cell:     "=2.0/6.0",
+		expected: "1/3",
+	},
+	numberTestCase{
+		name:     "rational as fractions",
+		numbers:  RationalNumberProvider{Fractions: true},
+		cell:     "=1.5*1.0",
+		expected: "3/2",
+	},
+	numberTestCase{
+		name:     "rational irrational power",
+		numbers:  RationalNumberProvider{Precision: 20},
+		cell:     "=2.0^0.5",
+		expected: "1.4142135623730950488",
+	},
+	numberTestCase{
+		name:     "rational fractional power",
+		numbers:  RationalNumberProvider{},
+		cell:     "=8.0^0.25",
+		expected: "1.68179283050742908606225095246643",
+	},
+	numberTestCase{
+		name:     "rational compound interest",
+		numbers:  RationalNumberProvider{},
+		cell:     "=(1+0.05/365)^(365*30)",
+		expected: "4.481228688524515247752280085827483",
+	},
+	numberTestCase{
+		name:     "rational large odd power of a negative number",
+		numbers:  RationalNumberProvider{Precision: 10},
+		cell:     "=(0-1.0001)^10951",
+		expected: "-2.989317943",
+	},
+	numberTestCase{
+		name:     "rational power of one",
+		numbers:  RationalNumberProvider{},
+		cell:     "=1^0.000000000000000000001",
+		expected: "1",
+	},
+	numberTestCase{
+		name:     "rational power of minus one",
+		numbers:  RationalNumberProvider{},
+		cell:     "=(0-1)^(1E30+1)",
+		expected: "-1",
+	},
+	numberTestCase{
+		name:     "rational power with a large denominator",
+		numbers:  RationalNumberProvider{},
+		cell:     "=2^0.000000000000000000001",
+		expected: "1.00000000000000000000069314718056",
+	},
+	numberTestCase{
+		name:     "fixed point",
+		numbers:  FixedPointNumberProvider{Scale: 2},
+		cell:     "=0.1+0.2",
+		expected: "0.3",
+	},
+	numberTestCase{
+		name:     "fixed point literal rounds half even",
+		numbers:  FixedPointNumberProvider{Scale: 2},
+		cell:     "=10.005+0.0",
+		expected: "10",
+	},
+	numberTestCase{
+		name:     "fixed point division rounds half up",
+		numbers:  FixedPointNumberProvider{Scale: 2, Rounding: RoundHalfUp},
+		cell:     "=2.0/3.0",
+		expected: "0.67",
+	},
+	numberTestCase{
+		name:     "fixed point multiplication",
+		numbers:  FixedPointNumberProvider{Scale: 4},
+		cell:     "=-2.5*1.0005",
+		expected: "-2.5012",
+	},
+	numberTestCase{
+		name:     "fixed point overflow",
+		numbers:  FixedPointNumberProvider{Scale: 2},
+		cell:     "=92233720368547758.07+0.01",
+		expected: "#NUM!",
+	},
+	numberTestCase{
+		name:     "fixed point power is rounded once",
+		numbers:  FixedPointNumberProvider{Scale: 2},
+		cell:     "=1.05^10",
+		expected: "1.63",
+	},
+	numberTestCase{
+		name:     "fixed point compound interest",
+		numbers:  FixedPointNumberProvider{Scale: 6},
+		cell:     "=1000*1.05^10",
+		expected: "1628.895",
+	},
+	numberTestCase{
+		name:     "fixed point negative power",
+		numbers:  FixedPointNumberProvider{Scale: 2},
+		cell:     "=1.05^-10",
+		expected: "0.61",
+	},
+	numberTestCase{
+		name:     "fixed point negative power of a fraction",
+		numbers:  FixedPointNumberProvider{Scale: 2},
+		cell:     "=0.3^-3",
+		expected: "37.04",
+	},
+	numberTestCase{
+		name:     "fixed point negative power overflow",
+		numbers:  FixedPointNumberProvider{Scale: 2},
+		cell:     "=0.5^-100",
+		expected: "#NUM!",
+	},
+	numberTestCase{
+		name:     "fixed point fractional power",
+		numbers:  FixedPointNumberProvider{Scale: 4},
+		cell:     "=2^0.5",
+		expected: "1.4142",
+	},
+	numberTestCase{
+		name:     "fixed point percent at the largest scale",
+		numbers:  FixedPointNumberProvider{Scale: MaxFixedPointScale},
+		cell:     "=50%",
+		expected: "0.5",
+	},
+	numberTestCase{
+		name:     "fixed point large product",
+		numbers:  FixedPointNumberProvider{Scale: 4},
+		cell:     "=900000000000000.0*10.0",
+		expected: "#NUM!",
+	},
+}
+
+func TestNumberProviders(t *testing.T) {
+	for _, c := range numberCases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := Context{Numbers: c.numbers}
+			n, errors, err := Parse(strings.NewReader(c.cell), ctx)
+			if err != nil || len(errors) != 0 {
+				t.Fatalf("Got parse errors: %v %v", err, errors)
+			}
+			v := Eval(n, ctx)
+			actual := ""
+			switch v.IsA {
+			case ValueKindNumber:
+				actual = v.Number.String()
+			case ValueKindText:
+				actual = v.Text
+			case ValueKindLogical:
+				actual = logicalText(v.Logical)
+			case ValueKindError:
+				actual = AsExcelError(v.Error).String()
+			}
+			if actual != c.expected {
+				t.Errorf("Expected %v, but got %v", c.expected, v)
+			}
+		})
+	}
+}
@@ -1,72 +1,28 @@
 package effe
 
 import (
-	"fmt"
+	"errors"
 	"io"
-	"log"
-	"runtime/debug"
+	"strings"
 	"unicode"
 )
 
-type node struct {
-	kind          nodeKind
-	rawValue      string
-	literalValue  Value
-	children      []*node
-	operatorValue operator
-}
-
-type nodeKind int
-
-const (
-	NodeKindFunction nodeKind = iota
-	NodeKindLiteral
-	NodeKindOperator
-	NodeKindHole
-)
-
-type operator int
-
-const (
-	Intersection operator = iota
-	UnaryNegation
-	Percent
-	Exponentiation
-	Multiplication
-	Division
-	Addition
-	Subtraction
-	Concatenation
-	Equality
-	GreaterThan
-	LessThan
-	GreaterThanOrEqual
-	LessThanOrEqual
-	Inequality
-)
-
-type parseError struct {
-	location uint
-	message  string
-}
-
-func (pe *parseError) Error() string {
-	return pe.message
-}
-
-func Parse(r io.RuneScanner, ctx Context) (*node, []parseError, error) {
-	var p *parser
-	defer func() {
-		if err := recover(); err != nil {
-			fmt.Println("Panic parsing: ", err)
-			fmt.Println("Parser: ", *p)
-			debug.PrintStack()
-		}
-	}()
-	p = newParser(r, ctx)
+// Parse parses the text of a cell, which is a formula when it starts with '='
+// and text otherwise. Problems with the text are reported as ParseErrors, and
+// joined into the error, which is nil only when there are none. The syntax tree
+// is nil when none can be built.
+func Parse(r io.RuneScanner, ctx Context) (*Node, []ParseError, error) {
+	p := newParser(r, ctx)
 	p.scanCell()
 	p.parse()
-	return p.next[0], p.parseErrors, nil
+	errs := make([]error, len(p.parseErrors))
+	for i, pe := range p.parseErrors {
+		errs[i] = pe
+	}
+	if len(p.next) != 1 {
+		return nil, p.parseErrors, errors.Join(errs...)
+	}
+	return p.next[0], p.parseErrors, errors.Join(errs...)
 }
 
 const (
@@ -89,44 +45,66 @@ const (
 type token struct {
 	value         string
 	typ           string
-	operatorValue operator
+	operatorValue Operator
+	span          Span
+}
+
+func (p *parser) addError(s Span, message string) {
+	p.c.debug("parse error", "message", message, "span", s)
+	p.parseErrors = append(p.parseErrors, ParseError{s, message})
 }
 
 func (p *parser) read() (r rune, cont bool) {
-	c, _, err := p.r.ReadRune()
-	p.count = p.count + 1
-	if err != nil && err != io.EOF {
-		p.parseErrors = append(p.parseErrors, parseError{p.count, "unexpected error reading: " + err.Error()})
+	c, size, err := p.r.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			p.addError(Span{p.pos, p.pos}, "unexpected error reading: "+err.Error())
+		}
 		return 0, false
 	}
-	return c, err != io.EOF
+	p.lastSize = size
+	p.pos.Byte += size
+	p.pos.Rune++
+	return c, true
 }
 
 func (p *parser) unread() {
-	err := p.r.UnreadRune()
-	p.count = p.count - 1
-	if err != nil {
-		p.parseErrors = append(p.parseErrors, parseError{p.count, "unexpected error unreading: " + err.Error()})
+	if err := p.r.UnreadRune(); err != nil {
+		p.addError(Span{p.pos, p.pos}, "unexpected error unreading: "+err.Error())
+		return
 	}
+	p.pos.Byte -= p.lastSize
+	p.pos.Rune--
 }
 
+// accumulateToken adds a token spanning from tokenStart to the current position
 func (t *parser) accumulateToken(v string, typ string) {
 	token := token{
 		value: v,
 		typ:   typ,
+		span:  Span{t.tokenStart, t.pos},
 	}
 
 	if typ == TokenTypeOperator {
-		token.operatorValue = getOperator(v)
+		o, ok := getOperator(v)
+		if !ok {
+			t.addError(token.span, "unknown operator: "+v)
+			token.typ = TokenTypeUnknown
+		}
+		token.operatorValue = o
 	}
 
+	t.c.debug("token", "value", token.value, "type", token.typ, "span", token.span)
 	t.tokens = append(t.tokens, token)
 }
 
 func (t *parser) scanCell() {
-	r, _ := t.read()
-	if r != '=' {
-		s := []rune{r}
+	r, cont := t.read()
+	if r != '=' || !cont {
+		s := []rune{}
+		if cont {
+			s = append(s, r)
+		}
 		for r, ok := t.read(); ok; r, ok = t.read() {
 			s = append(s, r)
 		}
@@ -179,65 +157,145 @@ func (t *parser) scanRepeated(predicate func(rune) bool) string {
 	return string(runes)
 }
 
-func (t *parser) scanCharacters() string {
-	return t.scanRepeated(unicode.IsLetter)
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
 }
 
 func (t *parser) scanDigits() string {
-	return t.scanRepeated(unicode.IsDigit)
+	return t.scanRepeated(isDigit)
 }
 
-func (t *parser) dropIfPresent(r rune) {
-	c, _ := t.read()
-	if c == r {
-		return
-	}
-	t.unread()
+// Function names and references, eg: STDEV.S, $A$1
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '$' || r == '.' || r == '_'
 }
 
-// Expects either 'A1' or '1' leading string.
+func isReferenceRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '$'
+}
+
+// Expects either 'A1:', 'A:' or '1:' leading string, and scans the other end of
+// the range. Any '$' markers are kept.
 func (t *parser) scanRangeSecondHalf(leading string) bool {
-	// TODO: dropping out '$' mean that the parsed representation can be no help in moving formulas around.
-	t.dropIfPresent('$')
-	rest := t.scanCharacters()
-	leading = leading + rest
-	t.dropIfPresent('$')
-	rest = t.scanDigits()
-	leading = leading + rest
-	t.accumulateToken(leading, TokenTypeRange)
+	rest := t.scanRepeated(isReferenceRune)
+	t.accumulateToken(leading+rest, TokenTypeRange)
+	return true
+}
+
+// scanText scans the rest of a double quoted text literal, where "" stands for
+// a single '"'.
+func (t *parser) scanText() bool {
+	runes := []rune{}
+	for {
+		r, cont := t.read()
+		if !cont {
+			t.addError(Span{t.tokenStart, t.pos}, "unterminated text")
+			t.accumulateToken(string(runes), TokenTypeText)
+			return false
+		}
+		if r == '"' {
+			r, cont = t.read()
+			if r != '"' || !cont {
+				if cont {
+					t.unread()
+				}
+				t.accumulateToken(string(runes), TokenTypeText)
+				return cont
+			}
+		}
+		runes = append(runes, r)
+	}
+}
+
+// scanError scans the rest of an error literal, eg: #DIV/0!, ignoring case
+func (t *parser) scanError() bool {
+	s := "#"
+	for {
+		r, cont := t.read()
+		if !cont {
+			break
+		}
+		if !isErrorPrefix(s + string(unicode.ToUpper(r))) {
+			t.unread()
+			break
+		}
+		s = s + string(unicode.ToUpper(r))
+	}
+
+	for _, e := range excelErrors {
+		if e.String() == s {
+			t.accumulateToken(s, TokenTypeError)
+			return true
+		}
+	}
+	// Take the rest of what looks like an error, eg: #DIV/9!, as one token
+	s = s + t.scanRepeated(isErrorRune)
+	t.addError(Span{t.tokenStart, t.pos}, "unknown error: "+s)
+	t.accumulateToken(s, TokenTypeUnknown)
 	return true
 }
 
-// given a leading column, scan the rest of a range token (digits)
-func (t *parser) scanRange(leading string) bool {
-	rest := t.scanDigits()
-	leading = leading + rest
+func isErrorRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '/' || r == '!' || r == '?' || r == '_'
+}
+
+func isErrorPrefix(s string) bool {
+	for _, e := range excelErrors {
+		if strings.HasPrefix(e.String(), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanNumber scans a number, eg: 12, 1.5, .5, 1.5E-3, or the rows of a range,
+// eg: 1:3. The text of a number is kept exactly as written, and it is left to
+// the NumberProvider to reject malformed numbers, eg: 1E+
+func (t *parser) scanNumber() bool {
+	s := t.scanDigits()
 	r, cont := t.read()
-	if !cont {
-		t.accumulateToken(leading, TokenTypeRange)
-		return false
+	if cont && r == ':' && s != "" {
+		return t.scanRangeSecondHalf(s + ":")
+	}
+	if cont && r == '.' {
+		s = s + "." + t.scanDigits()
+		r, cont = t.read()
 	}
-	if r != ':' {
+	if cont && (r == 'e' || r == 'E') {
+		s = s + string(r)
+		r, cont = t.read()
+		if cont && (r == '+' || r == '-') {
+			s = s + string(r)
+			r, cont = t.read()
+		}
+		if cont && isDigit(r) {
+			t.unread()
+			s = s + t.scanDigits()
+			r, cont = t.read()
+		}
+	}
+	if cont {
 		t.unread()
-		t.accumulateToken(leading, TokenTypeRange)
-		return true
-	} else {
-		leading = leading + ":"
-		return t.scanRangeSecondHalf(leading)
 	}
+	t.accumulateToken(s, TokenTypeNumber)
+	return cont
 }
 
 func (t *parser) scanFormulaToken() bool {
 	if !t.consumeWhiteSpace() {
-		fmt.Println("falling from consumeWhitespace")
 		return false
 	}
+	t.tokenStart = t.pos
 	r, cont := t.read()
 	if !cont {
 		return cont
 	}
 
 	switch r {
+	case '"':
+		return t.scanText()
+	case '#':
+		return t.scanError()
 	case ',':
 		t.accumulateToken("", TokenTypeSeprator)
 		return true
@@ -259,9 +317,25 @@ func (t *parser) scanFormulaToken() bool {
 		fallthrough
 	case '^':
 		fallthrough
-	case '>':
+	case '&':
+		fallthrough
+	case '=':
 		t.accumulateToken(string(r), TokenTypeOperator)
 		return true
+	case '>':
+		r, cont = t.read()
+		if cont != true {
+			t.accumulateToken(">", TokenTypeOperator)
+			return cont
+		}
+		if r == '=' {
+			t.accumulateToken(">=", TokenTypeOperator)
+			return true
+		} else {
+			t.unread()
+			t.accumulateToken(">", TokenTypeOperator)
+			return true
+		}
 	case '<':
 		r, cont = t.read()
 		if cont != true {
@@ -271,6 +345,9 @@ func (t *parser) scanFormulaToken() bool {
 		if r == '>' {
 			t.accumulateToken("<>", TokenTypeOperator)
 			return true
+		} else if r == '=' {
+			t.accumulateToken("<=", TokenTypeOperator)
+			return true
 		} else {
 			t.unread()
 			t.accumulateToken("<", TokenTypeOperator)
@@ -279,82 +356,68 @@ func (t *parser) scanFormulaToken() bool {
 	}
 
 	if unicode.IsLetter(r) || r == '$' {
-		// If it's a letter, read all the letters.
-		if r != '$' {
-			t.unread()
-		}
-		s := t.scanCharacters()
+		t.unread()
+		s := t.scanRepeated(isNameRune)
 
 		// This might be a formula or a range
 		r, cont = t.read()
-		if !cont {
-			return false
-		}
-		if r == '(' {
+		if cont && r == '(' {
+			t.unread()
 			t.accumulateToken(s, TokenTypeFunction)
+			t.tokenStart = t.pos
+			t.read()
 			t.accumulateToken("(", TokenTypeOpen)
 			return true
 		}
-		// If a digit or '$', then a range.
-		if unicode.IsDigit(r) || r == '$' {
-			if r != '$' {
-				t.unread()
-			}
-			return t.scanRange(s)
-		} else if r == ':' {
-			s = s + ":"
-			return t.scanRangeSecondHalf(s)
-
-		} else {
+		if cont && r == ':' {
+			return t.scanRangeSecondHalf(s + ":")
+		}
+		if cont {
 			t.unread()
+		}
+		if strings.EqualFold(s, "TRUE") || strings.EqualFold(s, "FALSE") {
+			t.accumulateToken(strings.ToUpper(s), TokenTypeLogical)
+		} else if _, err := ParseCellRef(s); err == nil {
+			t.accumulateToken(s, TokenTypeRange)
+		} else {
+			t.addError(Span{t.tokenStart, t.pos}, "unknown name: "+s)
 			t.accumulateToken(s, TokenTypeUnknown)
-			return true
 		}
+		return cont
 	}
 
 	// Could be a number, or a range.
-	if unicode.IsDigit(r) {
+	if isDigit(r) || r == '.' {
 		t.unread()
-		leading := t.scanDigits()
-		r, cont := t.read()
-		if !cont {
-			// TODO: what about r?
-			t.accumulateToken(leading, TokenTypeNumber)
-			return false
-		}
-
-		if r == ':' {
-			t.unread()
-			return t.scanRangeSecondHalf(leading)
-		} else if r == '.' {
-			rest := t.scanDigits()
-			t.accumulateToken(leading+"."+rest, TokenTypeNumber)
-			return true
-		} else {
-			t.unread()
-			return true
-		}
-
+		return t.scanNumber()
 	}
-	// Should never get here!
+	t.addError(Span{t.tokenStart, t.pos}, "unexpected character: "+string(r))
+	t.accumulateToken(string(r), TokenTypeUnknown)
 	return true
 }
 
 type parser struct {
-	count       uint
+	// The position of the next rune, and the size of the last one read
+	pos      Position
+	lastSize int
+	// Where the token being scanned started
+	tokenStart  Position
 	r           io.RuneScanner
-	parseErrors []parseError
+	parseErrors []ParseError
 	// TODO: we can get rid of this, and parse the tokens as they come "off the line".
 	// Leaving this in because we can have tests that focus on tokenization when this is easily
 	// inspectable
 	tokens []token
 	// position in token stream
-	position      int
-	operator      []token
-	next          []*node
-	argCountStack []int
+	position int
+	operator []token
+	next     []*Node
+	// The calls being parsed, innermost last
+	calls []call
 	// Used to distinguish infix and unary minus
 	infix bool
+	// The operator still waiting for its right operand, eg: the + in =1+
+	pending *token
 	// Used to populate literals
 	c Context
 }
@@ -362,10 +425,9 @@ type parser struct {
 func newParser(r io.RuneScanner, ctx Context) *parser {
 	return &parser{
 		position:    0,
-		count:       0,
 		r:           r,
 		tokens:      []token{},
-		parseErrors: []parseError{},
+		parseErrors: []ParseError{},
 		c:           ctx,
 	}
 }
@@ -380,13 +442,6 @@ func (p *parser) readToken() token {
 	return t
 }
 
-func (p *parser) unreadToken() {
-	if p.position == 0 {
-		panic("cannot unread past start of tokens")
-	}
-	p.position--
-}
-
 func (p *parser) peek() token {
 	return p.tokens[p.position]
 }
@@ -407,46 +462,85 @@ func (p *parser) moreOperator() bool {
 	return len(p.operator) > 0
 }
 
-func getOperator(s string) operator {
+func getOperator(s string) (Operator, bool) {
 	switch s {
 	case "-u":
-		return UnaryNegation
+		return UnaryNegation, true
 	case "%":
-		return Percent
+		return Percent, true
 	case "^":
-		return Exponentiation
+		return Exponentiation, true
 	case "*":
-		return Multiplication
+		return Multiplication, true
 	case "/":
-		return Division
+		return Division, true
 	case "+":
-		return Addition
+		return Addition, true
 	case "-":
-		return Subtraction
+		return Subtraction, true
 	case "&":
-		return Concatenation
+		return Concatenation, true
 	case "=":
-		return Equality
+		return Equality, true
 	case ">":
-		return GreaterThan
+		return GreaterThan, true
 	case "<":
-		return LessThan
+		return LessThan, true
 	case ">=":
-		return GreaterThanOrEqual
+		return GreaterThanOrEqual, true
 	case "<=":
-		return LessThanOrEqual
+		return LessThanOrEqual, true
 	case "<>":
-		return Inequality
-	default:
-		panic("Unkown operator: " + s)
+		return Inequality, true
 	}
+	return 0, false
 }
 
-func operatorPrecedence(o operator) int {
+// operatorSymbol is the inverse of getOperator
+func operatorSymbol(o Operator) string {
 	switch o {
 	case Intersection:
-		return 8
+		return " "
 	case UnaryNegation:
+		return "-"
+	case UnaryPlus:
+		return "+"
+	case Percent:
+		return "%"
+	case Exponentiation:
+		return "^"
+	case Multiplication:
+		return "*"
+	case Division:
+		return "/"
+	case Addition:
+		return "+"
+	case Subtraction:
+		return "-"
+	case Concatenation:
+		return "&"
+	case Equality:
+		return "="
+	case GreaterThan:
+		return ">"
+	case LessThan:
+		return "<"
+	case GreaterThanOrEqual:
+		return ">="
+	case LessThanOrEqual:
+		return "<="
+	case Inequality:
+		return "<>"
+	}
+	// should never happen
+	return "?"
+}
+
+func operatorPrecedence(o Operator) int {
+	switch o {
+	case Intersection:
+		return 8
+	case UnaryNegation, UnaryPlus:
 		return 7
 	case Percent:
 		return 6
@@ -479,9 +573,9 @@ func operatorPrecedence(o operator) int {
 	return 0
 }
 
-func operatorArgs(o operator) int {
+func operatorArgs(o Operator) int {
 	switch o {
-	case UnaryNegation:
+	case UnaryNegation, UnaryPlus:
 		return 1
 	case Percent:
 		return 1
@@ -490,112 +584,229 @@ func operatorArgs(o operator) int {
 	}
 }
 
-func leftAssociative(o operator) bool {
-	if o == UnaryNegation {
-		return false
-	}
-	return true
+func leftAssociative(o Operator) bool {
+	return !prefix(o)
+}
+
+// prefix reports whether o is written before its operand, eg: -1
+func prefix(o Operator) bool {
+	return o == UnaryNegation || o == UnaryPlus
 }
 
-// TODO: parse numbers
-// TODO: parse ranges
-// TODO: parse logical and text?
-func (p *parser) buildSimpleNode(t token) *node {
+func (p *parser) buildSimpleNode(t token) *Node {
 	switch t.typ {
 	case TokenTypeNumber:
 		// So parsing depends on our numeric model?
+		v := NumberValue(nil)
 		n, err := p.c.Numbers.ParseNumber(t.value)
-		// TODO: decorate nodes with position
 		if err != nil {
-			p.parseErrors = append(p.parseErrors, parseError{0, err.Error()})
+			p.addError(t.span, err.Error())
+			v = ErrorValue(AsExcelError(err))
+		} else {
+			v = NumberValue(n)
 		}
-		return &node{
+		return &Node{
 			kind:         NodeKindLiteral,
-			literalValue: NumberValue(n),
+			rawValue:     t.value,
+			literalValue: v,
+			span:         t.span,
 		}
 	case TokenTypeRange:
-		return &node{
-			kind:         NodeKindLiteral,
+		a, err := ParseArea(t.value)
+		if err != nil {
+			p.addError(t.span, err.Error())
+		}
+		return &Node{
+			kind:         NodeKindReference,
+			rawValue:     t.value,
 			literalValue: RangeValue(p.c.Ranges.ParseRange(t.value)),
+			area:         a,
+			span:         t.span,
 		}
 	case TokenTypeLogical:
-		return &node{
+		return &Node{
 			kind:         NodeKindLiteral,
-			literalValue: LogicalValue(t.value == "true"),
+			rawValue:     t.value,
+			literalValue: LogicalValue(strings.EqualFold(t.value, "TRUE")),
+			span:         t.span,
+		}
+	case TokenTypeError:
+		v := ErrorValue(ErrValue)
+		for _, e := range excelErrors {
+			if e.String() == t.value {
+				v = ErrorValue(e)
+			}
+		}
+		return &Node{
+			kind:         NodeKindLiteral,
+			rawValue:     t.value,
+			literalValue: v,
+			span:         t.span,
 		}
 	case TokenTypeText:
-		return &node{
+		return &Node{
 			kind:         NodeKindLiteral,
+			rawValue:     `"` + strings.ReplaceAll(t.value, `"`, `""`) + `"`,
 			literalValue: TextValue(t.value),
+			span:         t.span,
+		}
+	case TokenTypeUnknown:
+		// Already reported by the tokenizer, and evaluates like an unknown name
+		return &Node{
+			kind:         NodeKindLiteral,
+			rawValue:     t.value,
+			literalValue: ErrorValue(ErrName),
+			span:         t.span,
 		}
 	case TokenTypeNoop:
-		return &node{
+		return &Node{
 			kind: NodeKindHole,
+			span: t.span,
 		}
 	}
-	panic("unexpected token type for simple node" + t.typ)
+	p.addError(t.span, "unexpected "+t.typ)
+	return &Node{
+		kind: NodeKindHole,
+		span: t.span,
+	}
 }
 
-func (p *parser) outputOperator(o operator) {
-	var nargs = operatorArgs(o)
-	var n = &node{
-		kind:          NodeKindOperator,
-		operatorValue: o,
+// popOperands removes the last n nodes from the output. If there are not
+// enough, it reports an error at s, and holes stand in for the missing ones.
+func (p *parser) popOperands(n int, s Span) []*Node {
+	children := make([]*Node, n)
+	present := min(n, len(p.next))
+	if present < n {
+		p.addError(s, "missing operand")
 	}
-	n.children = make([]*node, nargs)
-	copy(n.children, p.next[len(p.next)-nargs:])
-	p.next = p.next[:len(p.next)-nargs]
-	p.next = append(p.next, n)
+	copy(children, p.next[len(p.next)-present:])
+	p.next = p.next[:len(p.next)-present]
+	for i := present; i < n; i++ {
+		children[i] = &Node{kind: NodeKindHole, span: Span{s.End, s.End}}
+	}
+	return children
 }
 
-func (p *parser) output(t token) {
-	// If the last one was a range (or a formula), implicitly create a ' ' intersection operator
-	if t.typ != TokenTypeNoop && t.typ != TokenTypeFunction && len(p.next) > 0 && p.next[len(p.next)-1].kind == NodeKindHole {
-		// Fill the hole.
-		p.next[len(p.next)-1] = p.buildSimpleNode(t)
-		return
-	}
+// missingOperand reports that the operator t has no operand at s, and outputs a
+// hole in its place, eg: before the * in =*2, or after the + in =1+
+func (p *parser) missingOperand(t token, s Span) {
+	p.addError(t.span, "missing operand")
+	p.output(token{typ: TokenTypeNoop, span: Span{s.Start, s.Start}})
+	p.infix = true
+}
 
-	if t.typ == TokenTypeRange && p.next[len(p.next)-1].kind != NodeKindHole {
-		p.next = append(p.next, p.buildSimpleNode(t))
-		p.outputOperator(Intersection)
-		return
+func (p *parser) outputOperator(t token) {
+	var nargs = operatorArgs(t.operatorValue)
+	var n = &Node{
+		kind:          NodeKindOperator,
+		operatorValue: t.operatorValue,
+		span:          t.span,
 	}
+	n.children = p.popOperands(nargs, t.span)
+	for _, c := range n.children {
+		n.span = n.span.union(c.span)
+	}
+	p.next = append(p.next, n)
+}
 
+// call counts the arguments of a function whose ')' has not yet been seen
+type call struct {
+	args int
+	// Whether nothing has been seen of the current argument, eg: after the
+	// first ',' in IF(A1,,3)
+	empty bool
+}
+
+func (p *parser) output(t token) {
 	if t.typ == TokenTypeRange ||
 		t.typ == TokenTypeNumber ||
 		t.typ == TokenTypeLogical ||
 		t.typ == TokenTypeText ||
+		t.typ == TokenTypeError ||
+		t.typ == TokenTypeUnknown ||
 		t.typ == TokenTypeNoop {
 		p.next = append(p.next, p.buildSimpleNode(t))
 	}
 
-	if t.typ == TokenTypeFunction {
-		nargs := p.argCountStack[len(p.argCountStack)-1]
-		log.Println("Function", nargs)
+	if t.typ == TokenTypeOperator {
+		p.outputOperator(t)
+	}
+}
 
-		//pop
-		p.argCountStack = p.argCountStack[:len(p.argCountStack)-1]
+// outputFunction outputs a call to t, taking its nargs arguments from the output
+func (p *parser) outputFunction(t token, nargs int) {
+	p.c.debug("function", "name", t.value, "args", nargs, "span", t.span)
 
-		n := &node{
-			kind:     NodeKindFunction,
-			rawValue: t.value,
-		}
-		n.children = make([]*node, nargs)
-		copy(n.children, p.next[len(p.next)-nargs:])
-		p.next = p.next[:len(p.next)-nargs]
-		p.next = append(p.next, n)
+	n := &Node{
+		kind:     NodeKindFunction,
+		rawValue: t.value,
+		span:     t.span,
+	}
+	n.children = p.popOperands(nargs, t.span)
+	for _, c := range n.children {
+		n.span = n.span.union(c.span)
 	}
+	p.next = append(p.next, n)
+}
 
-	if t.typ == TokenTypeOperator {
-		p.outputOperator(t.operatorValue)
+// endArgument finishes the current argument of the innermost call at s. An
+// empty argument is output as a hole.
+func (p *parser) endArgument(s Span) {
+	c := &p.calls[len(p.calls)-1]
+	if c.empty {
+		p.output(token{typ: TokenTypeNoop, span: Span{s.Start, s.Start}})
 	}
+	c.args++
+	c.empty = true
+}
+
+// endCall outputs the innermost call, whose last argument ends at s
+func (p *parser) endCall(t token, s Span) {
+	// No arguments at all, eg: NOW()
+	if c := p.calls[len(p.calls)-1]; c.args > 0 || !c.empty {
+		p.endArgument(s)
+	}
+	p.outputFunction(t, p.calls[len(p.calls)-1].args)
+	p.calls = p.calls[:len(p.calls)-1]
+}
+
+// popOperators outputs operators down to the innermost '(' or function
+func (p *parser) popOperators() {
+	for p.moreOperator() && p.peekOperator().typ == TokenTypeOperator {
+		p.output(p.peekOperator())
+		p.popOperator()
+	}
+}
+
+// pushOperatorToken outputs the operators that bind at least as tightly as t,
+// then pushes t.
+func (p *parser) pushOperatorToken(t token) {
+	for p.moreOperator() {
+		var next = p.peekOperator()
+		if next.typ != TokenTypeOperator {
+			break
+		}
+		if operatorPrecedence(next.operatorValue) > operatorPrecedence(t.operatorValue) ||
+			(operatorPrecedence(next.operatorValue) == operatorPrecedence(t.operatorValue) && leftAssociative(next.operatorValue)) {
+			p.output(next)
+			p.popOperator()
+			continue
+		}
+		break
+	}
+
+	p.pushOperator(t)
 }
 
 func (p *parser) parse() {
 	// Shunting yard algorithm
 	for p.more() {
 		t := p.readToken()
+		pending := p.pending
+		p.pending = nil
+		if len(p.calls) > 0 && t.typ != TokenTypeSeprator && t.typ != TokenTypeClose {
+			p.calls[len(p.calls)-1].empty = false
+		}
 		switch t.typ {
 		// Values
 		case TokenTypeText:
@@ -606,110 +817,113 @@ func (p *parser) parse() {
 			fallthrough
 		case TokenTypeError:
 			fallthrough
+		case TokenTypeUnknown:
+			fallthrough
 		case TokenTypeRange:
+			if t.typ == TokenTypeRange && p.infix {
+				// Adjacent references, eg: =A:A 2:2, are joined by the intersection operator
+				p.pushOperatorToken(token{typ: TokenTypeOperator, operatorValue: Intersection, span: Span{t.span.Start, t.span.Start}})
+			}
 			p.infix = true
 			p.output(t)
 
 		case TokenTypeFunction:
 			p.infix = false
 
-			if p.peek().typ != TokenTypeOpen {
-				// ERROR
+			if !p.more() || p.peek().typ != TokenTypeOpen {
+				p.addError(t.span, "missing ( after "+t.value)
+				continue
 			}
-			// Drop the open, we'll treat it as being subsumed into the function
+			// The open is subsumed into the function
 			p.readToken()
 			p.pushOperator(t)
-			p.argCountStack = append(p.argCountStack, 1)
-			// Placeholder in case we get no arguments
-			// output needs to special case a 1-arg function with noop arg
-			p.output(token{typ: TokenTypeNoop})
+			p.calls = append(p.calls, call{empty: true})
 
 		case TokenTypeOperator:
 
-			// Check if subtraction should be converted to unary minus
+			// Check if subtraction or addition should be converted to unary minus or plus
 			if !p.infix && t.operatorValue == Subtraction {
 				t.operatorValue = UnaryNegation
+			} else if !p.infix && t.operatorValue == Addition {
+				t.operatorValue = UnaryPlus
 			}
-			p.infix = false
-
-			for p.moreOperator() {
-				var next = p.peekOperator()
-				if next.typ == TokenTypeOpen {
-					break
-				}
-				if next.typ == TokenTypeFunction {
-					p.output(next)
-					p.popOperator()
-				}
-				if next.typ == TokenTypeOperator {
-					if operatorPrecedence(next.operatorValue) > operatorPrecedence(t.operatorValue) ||
-						(operatorPrecedence(next.operatorValue) == operatorPrecedence(t.operatorValue) && leftAssociative(next.operatorValue)) {
-						p.output(next)
-						p.popOperator()
-					}
-				}
-
+			if !p.infix && !prefix(t.operatorValue) {
+				p.missingOperand(t, t.span)
 			}
-
-			p.pushOperator(t)
+			// Percent is postfix, so what follows is still infix, eg: =50%-1
+			p.infix = t.operatorValue == Percent
+			if !p.infix {
+				p.pending = &t
+			}
+			p.pushOperatorToken(t)
 		case TokenTypeSeprator:
+			if pending != nil {
+				p.missingOperand(*pending, t.span)
+			}
 			p.infix = false
 
-			// Pop operators until we get to a function
-			// increment the most recent
-			for {
-				o := p.operator[len(p.operator)-1]
-				if o.typ == TokenTypeFunction {
-					break
-				} else {
-					p.output(o)
-					p.operator = p.operator[:len(p.operator)-2]
-				}
+			p.popOperators()
+			if !p.moreOperator() || p.peekOperator().typ != TokenTypeFunction {
+				p.addError(t.span, "unexpected ,")
+				continue
 			}
-			p.argCountStack[len(p.argCountStack)] = p.argCountStack[len(p.argCountStack)] + 1
-			p.output(token{typ: TokenTypeNoop})
-			// TODO: this breaks for 1 + sum(,B2)
+			p.endArgument(t.span)
 		case TokenTypeOpen:
 			p.infix = false
 
 			p.operator = append(p.operator, t)
 		case TokenTypeClose:
-			p.infix = false
-
-			// Pop until we get to a function or an open.
-			// If left paren -- pop and discard
-			// If function
-			// push to output
-			for {
-				if len(p.operator) == 0 {
-					// ERROR unbalanced parens
-				}
-				o := p.operator[len(p.operator)-1]
-				if o.typ == TokenTypeOpen {
-					p.operator = p.operator[:len(p.operator)-2]
-					break
-				} else if o.typ == TokenTypeFunction {
-					// Leave it for something else to pop and push over, or the final cleanup
-					break
-				} else {
-					p.output(o)
-					p.operator = p.operator[:len(p.operator)-2]
+			if pending != nil {
+				p.missingOperand(*pending, t.span)
+			}
+			p.popOperators()
+			if !p.moreOperator() {
+				p.addError(t.span, "unbalanced )")
+				continue
+			}
+			o := p.peekOperator()
+			p.popOperator()
+			if o.typ == TokenTypeFunction {
+				// The call spans up to its closing parenthesis
+				o.span.End = t.span.End
+				p.endCall(o, t.span)
+			} else {
+				if !p.infix {
+					p.addError(o.span.union(t.span), "missing expression")
+					p.output(token{typ: TokenTypeNoop, span: Span{t.span.Start, t.span.Start}})
 				}
-
+				// The parenthesised expression spans its parentheses
+				last := p.next[len(p.next)-1]
+				last.span = last.span.union(o.span).union(t.span)
 			}
+			// A closed parenthesis is a value, so what follows is infix, eg: =(1)-2
+			p.infix = true
 		default:
-			panic("unexected token type:" + t.typ)
+			p.addError(t.span, "unexpected "+t.typ)
 		}
 	}
 
+	if p.pending != nil {
+		p.missingOperand(*p.pending, Span{p.pos, p.pos})
+	}
 	for p.moreOperator() {
 		t := p.peekOperator()
-		if t.typ == TokenTypeOpen {
-			panic("unbalanced parens")
-		}
-		p.output(t)
 		p.popOperator()
+		switch t.typ {
+		case TokenTypeOpen:
+			p.addError(t.span, "unbalanced (")
+		case TokenTypeFunction:
+			p.addError(t.span, "unbalanced (")
+			p.endCall(t, Span{p.pos, p.pos})
+		default:
+			p.output(t)
+		}
 	}
 
-	//
+	if len(p.next) == 0 {
+		p.addError(Span{p.pos, p.pos}, "missing formula")
+	}
+	for _, n := range p.next[min(1, len(p.next)):] {
+		p.addError(n.span, "unexpected value")
+	}
 }
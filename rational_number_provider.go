@@ -0,0 +1,303 @@
+package effe
+
+import (
+	"math"
+	"math/big"
+)
+
+// Rationals whose numerator or denominator would exceed this many bits are
+// reported as #NUM!, far beyond the largest number excel can hold.
+const rationalMaxBits = 1 << 16
+
+// RationalNumberProvider implements exact arithmetic on fractions, so Add, Sub,
+// Mul and Div never round, and =1/3*3 is exactly 1. Results that cannot be
+// fractions, such as =2^0.5, are computed to Precision significant digits
+// (DefaultDecimalPrecision when zero).
+//
+// Numbers render as a decimal when their decimal expansion terminates, and as a
+// fraction such as 1/3 otherwise. Setting Fractions renders every number as a
+// fraction.
+type RationalNumberProvider struct {
+	Precision uint
+	Fractions bool
+}
+
+type ratNumber struct {
+	r         *big.Rat
+	fractions bool
+}
+
+func (n ratNumber) String() string {
+	if n.fractions {
+		return n.r.RatString()
+	}
+	// The expansion terminates when the denominator divides a power of ten, ie:
+	// it has no prime factors besides 2 and 5.
+	d := new(big.Int).Set(n.r.Denom())
+	m := new(big.Int)
+	places := 0
+	for _, f := range []*big.Int{big.NewInt(2), big.NewInt(5)} {
+		count := 0
+		for {
+			q, r := new(big.Int).QuoRem(d, f, m)
+			if r.Sign() != 0 {
+				break
+			}
+			d = q
+			count++
+		}
+		if count > places {
+			places = count
+		}
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return n.r.RatString()
+	}
+	coef := new(big.Int).Mul(n.r.Num(), pow10(places))
+	coef.Quo(coef, n.r.Denom())
+	return decimalNumber{coef: coef, exp: -places}.String()
+}
+
+func (p RationalNumberProvider) precision() int {
+	if p.Precision == 0 {
+		return DefaultDecimalPrecision
+	}
+	return int(p.Precision)
+}
+
+func (p RationalNumberProvider) number(r *big.Rat) (Number, error) {
+	if r.Num().BitLen() > rationalMaxBits || r.Denom().BitLen() > rationalMaxBits {
+		return nil, ErrNum
+	}
+	return ratNumber{r: r, fractions: p.Fractions}, nil
+}
+
+// fromDecimal converts an exactly represented decimal to a fraction
+func (p RationalNumberProvider) fromDecimal(d decimalNumber) (Number, error) {
+	return p.number(d.rat())
+}
+
+// ParseNumber accepts the same syntax as DecimalNumberProvider, and is exact
+func (p RationalNumberProvider) ParseNumber(text string) (Number, error) {
+	// Enough precision that no literal is ever rounded
+	d, err := DecimalNumberProvider{Precision: uint(len(text))}.ParseNumber(text)
+	if err != nil {
+		return nil, err
+	}
+	return p.fromDecimal(d.(decimalNumber))
+}
+
+func (p RationalNumberProvider) FromInt(i int64) Number {
+	return ratNumber{r: new(big.Rat).SetInt64(i), fractions: p.Fractions}
+}
+
+func (p RationalNumberProvider) Add(a Number, b Number) (Number, error) {
+	return p.number(new(big.Rat).Add(a.(ratNumber).r, b.(ratNumber).r))
+}
+
+func (p RationalNumberProvider) Sub(a Number, b Number) (Number, error) {
+	return p.number(new(big.Rat).Sub(a.(ratNumber).r, b.(ratNumber).r))
+}
+
+func (p RationalNumberProvider) Mul(a Number, b Number) (Number, error) {
+	return p.number(new(big.Rat).Mul(a.(ratNumber).r, b.(ratNumber).r))
+}
+
+func (p RationalNumberProvider) Div(a Number, b Number) (Number, error) {
+	br := b.(ratNumber).r
+	if br.Sign() == 0 {
+		return nil, ErrDiv0
+	}
+	return p.number(new(big.Rat).Quo(a.(ratNumber).r, br))
+}
+
+// Pow is exact for integer exponents, as long as the fraction stays within
+// rationalMaxBits, eg: not =1.0001^10950. Otherwise the result is rounded to
+// Precision significant digits, see fractionalPow.
+func (p RationalNumberProvider) Pow(a Number, b Number) (Number, error) {
+	ar := a.(ratNumber).r
+	br := b.(ratNumber).r
+	if ar.Sign() == 0 {
+		switch br.Sign() {
+		case 0:
+			return nil, ErrNum
+		case -1:
+			return nil, ErrDiv0
+		}
+		return a, nil
+	}
+
+	if br.IsInt() {
+		n := br.Num()
+		// Powers of 1 and -1 are exact however large the exponent
+		if new(big.Rat).Abs(ar).Cmp(big.NewRat(1, 1)) == 0 {
+			return p.number(powInt(ar, int64(n.Bit(0))))
+		}
+		if n.IsInt64() && exactPowFits(ar, n.Int64()) {
+			return p.number(powInt(ar, n.Int64()))
+		}
+	} else if ar.Sign() < 0 {
+		return nil, ErrNum
+	}
+
+	result, ok := fractionalPow(new(big.Rat).Abs(ar), br, p.precision())
+	if !ok {
+		return nil, ErrNum
+	}
+	// Odd powers of a negative number are negative
+	if ar.Sign() < 0 && br.Num().Bit(0) == 1 {
+		result.Neg(result)
+	}
+	// Round to the configured number of significant digits, and convert back.
+	rounded, err := DecimalNumberProvider{Precision: uint(p.precision())}.ParseNumber(result.Text('e', p.precision()-1))
+	if err != nil {
+		return nil, err
+	}
+	return p.fromDecimal(rounded.(decimalNumber))
+}
+
+// exactPowFits reports whether a^n fits in rationalMaxBits
+func exactPowFits(a *big.Rat, n int64) bool {
+	if n < -rationalMaxBits || n > rationalMaxBits {
+		return false
+	}
+	bits := int64(max(a.Num().BitLen(), a.Denom().BitLen()))
+	return bits*max(n, -n) <= rationalMaxBits
+}
+
+func powInt(a *big.Rat, n int64) *big.Rat {
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	num := new(big.Int).Exp(a.Num(), big.NewInt(n), nil)
+	den := new(big.Int).Exp(a.Denom(), big.NewInt(n), nil)
+	if negative {
+		num, den = den, num
+	}
+	return new(big.Rat).SetFrac(num, den)
+}
+
+// nthRoot finds the positive n'th root of a positive x at the precision of x
+func nthRoot(x *big.Float, n int64) (*big.Float, bool) {
+	prec := x.Prec()
+	mant := new(big.Float)
+	exp := x.MantExp(mant)
+	m, _ := mant.Float64()
+	guess := math.Exp((math.Log(m) + float64(exp)*math.Ln2) / float64(n))
+	if guess == 0 || math.IsInf(guess, 0) || math.IsNaN(guess) {
+		return nil, false
+	}
+
+	// y' = ((n-1)y + x/y^(n-1)) / n
+	y := new(big.Float).SetPrec(prec).SetFloat64(guess)
+	nf := new(big.Float).SetPrec(prec).SetInt64(n)
+	n1 := new(big.Float).SetPrec(prec).SetInt64(n - 1)
+	tolerance := new(big.Float).SetMantExp(big.NewFloat(1), -int(prec))
+	for i := 0; i < 100; i++ {
+		next := new(big.Float).SetPrec(prec).Quo(x, floatPow(y, n-1))
+		next.Add(next, new(big.Float).SetPrec(prec).Mul(n1, y))
+		next.Quo(next, nf)
+
+		delta := new(big.Float).Sub(next, y)
+		y = next
+		if delta.Abs(delta).Cmp(new(big.Float).Mul(tolerance, y)) <= 0 {
+			break
+		}
+	}
+	return y, true
+}
+
+// fractionalPow raises the positive x to the power e, to about digits
+// significant digits. The integer part of e is applied by squaring, and its
+// fractional part one decimal digit at a time, as powers of successive 10th
+// roots of x, so every root converges quickly however small e is, or however
+// long its expansion, eg: 1/3.
+func fractionalPow(x *big.Rat, e *big.Rat, digits int) (*big.Float, bool) {
+	if x.Cmp(big.NewRat(1, 1)) == 0 {
+		return big.NewFloat(1), true
+	}
+	den := e.Denom()
+	whole, frac := new(big.Int).QuoRem(e.Num(), den, new(big.Int))
+	if frac.Sign() < 0 {
+		whole.Sub(whole, big.NewInt(1))
+		frac.Add(frac, den)
+	}
+	if !whole.IsInt64() {
+		return nil, false
+	}
+
+	// Digits of e past places change the result by less than a part in 10^digits
+	xf, _ := new(big.Float).SetRat(x).Float64()
+	lnx := math.Abs(math.Log(xf))
+	if math.IsInf(lnx, 0) {
+		lnx = float64(max(x.Num().BitLen(), x.Denom().BitLen()))
+	}
+	places := digits + 3 + int(math.Ceil(math.Log10(lnx+1)))
+	prec := uint(float64(places)*math.Log2(10)) + 64 + uint(whole.BitLen())
+
+	root := new(big.Float).SetPrec(prec).SetRat(x)
+	result := floatPow(root, whole.Int64())
+	digit, rem := new(big.Int), new(big.Int)
+	for i := 0; i < places && frac.Sign() != 0; i++ {
+		var ok bool
+		if root, ok = nthRoot(root, 10); !ok {
+			return nil, false
+		}
+		digit.QuoRem(frac.Mul(frac, bigTen), den, rem)
+		frac.Set(rem)
+		if digit.Sign() != 0 {
+			result.Mul(result, floatPow(root, digit.Int64()))
+		}
+	}
+	if result.IsInf() {
+		return nil, false
+	}
+	return result, true
+}
+
+// floatPow raises x to the integer power n by squaring
+func floatPow(x *big.Float, n int64) *big.Float {
+	prec := x.Prec()
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	result := new(big.Float).SetPrec(prec).SetInt64(1)
+	base := new(big.Float).Copy(x)
+	for n > 0 {
+		if n&1 == 1 {
+			result.Mul(result, base)
+		}
+		n >>= 1
+		if n > 0 {
+			base.Mul(base, base)
+		}
+	}
+	if negative {
+		return result.Quo(new(big.Float).SetPrec(prec).SetInt64(1), result)
+	}
+	return result
+}
+
+func (p RationalNumberProvider) Neg(a Number) (Number, error) {
+	return p.number(new(big.Rat).Neg(a.(ratNumber).r))
+}
+
+func (p RationalNumberProvider) Cmp(a Number, b Number) int {
+	return a.(ratNumber).r.Cmp(b.(ratNumber).r)
+}
+
+func (p RationalNumberProvider) ToFloat(a Number) float64 {
+	f, _ := a.(ratNumber).r.Float64()
+	return f
+}
+
+func (p RationalNumberProvider) ToInt(a Number) (int64, error) {
+	ar := a.(ratNumber).r
+	i := new(big.Int).Quo(ar.Num(), ar.Denom())
+	if !i.IsInt64() {
+		return 0, ErrNum
+	}
+	return i.Int64(), nil
+}
@@ -0,0 +1,18 @@
+package effe
+
+import "iter"
+
+// populatedValues yields the cells of a that are not blank, skipping them
+// efficiently when rp is a SparseRangeProvider
+func populatedValues(rp RangeProvider, a Range) iter.Seq2[CellRef, Value] {
+	if sp, ok := rp.(SparseRangeProvider); ok {
+		return sp.PopulatedValues(a)
+	}
+	return func(yield func(CellRef, Value) bool) {
+		for ref, v := range rp.Values(a) {
+			if v.IsA != ValueKindBlank && !yield(ref, v) {
+				return
+			}
+		}
+	}
+}
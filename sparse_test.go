@@ -0,0 +1,26 @@
+package effe
+
+import "testing"
+
+// denseRanges hides the SparseRangeProvider methods of a provider
+type denseRanges struct {
+	RangeProvider
+}
+
+func TestDenseFallback(t *testing.T) {
+	rp := denseRanges{testRanges()}
+	if _, ok := RangeProvider(rp).(SparseRangeProvider); ok {
+		t.Fatal("Expected denseRanges not to be sparse")
+	}
+
+	count := 0
+	for _, v := range populatedValues(rp, rp.ParseRange("C:E")) {
+		if v.IsA == ValueKindBlank {
+			t.Errorf("Expected only populated cells, but got %v", v)
+		}
+		count++
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 populated cells, but got %v", count)
+	}
+}